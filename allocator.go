@@ -0,0 +1,136 @@
+package chunkpipe
+
+import "unsafe"
+
+// Allocator 為自有區塊提供底層記憶體，可用來接上 arena、記憶體池或 cgo 的配置器。
+//
+// Alloc 返回至少 nBytes 位元組、對齊元素型別的記憶體，無法配置時返回 nil，管道會改用一般的堆積配置。
+// Free 歸還先前由 Alloc 取得的記憶體，nBytes 與配置時相同。
+// 兩者都在持有管道寫鎖時呼叫；同一個 Allocator 供多個管道使用時需自行處理並行
+type Allocator interface {
+	Alloc(nBytes int) unsafe.Pointer
+	Free(ptr unsafe.Pointer, nBytes int)
+}
+
+// WithAllocator 讓不含指標的元素型別從 a 配置自有區塊。
+// 這些記憶體不受垃圾回收掃描，因此元素含有指標或大小為 0 時此選項沒有作用。
+//
+// 取空的區塊保留在空閒列表中重複使用，Clear 與 Close 時交還給 a。
+// PopChunkFront 等返回整個區塊的方法會先複製到堆積上；
+// PeekChunkFront、ChunkSlice 等視圖則與一般模式相同，只在下一次彈出或推入之前有效，
+// 之後再存取可能讀到已歸還的記憶體。
+// 被 Insert、Split 等切開，或以 CloneShared、SubPipe 共用的區塊會先複製到堆積上；
+// 以 Append、Split 整塊移到其他管道的區塊則改由接收的管道歸還
+func WithAllocator(a Allocator) Option {
+	return func(o *options) {
+		o.allocator = a
+	}
+}
+
+// chunkBase 返回自有區塊 c 從底層陣列起點算起、長度為 0 的完整切片，c.val 不可為空
+func chunkBase[T any](c offset[T]) []T {
+	// dead 為 val 之前已彈出的元素數量，往回推即為底層陣列的起點
+	base := unsafe.Add(unsafe.Pointer(unsafe.SliceData(c.val)), -c.dead*int(unsafe.Sizeof(*new(T))))
+	return unsafe.Slice((*T)(base), c.dead+cap(c.val))[:0]
+}
+
+// allocExternal 從 allocator 配置容量為 n 的區塊，失敗時返回 nil，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) allocExternal(n int) []T {
+	size := n * int(unsafe.Sizeof(*new(T)))
+	p := cl.allocator.Alloc(size)
+	if p == nil {
+		return nil
+	}
+	if uintptr(p)%unsafe.Alignof(*new(T)) != 0 {
+		cl.allocator.Free(p, size)
+		return nil
+	}
+	val := unsafe.Slice((*T)(p), n)[:0]
+	if cl.external == nil {
+		cl.external = make(map[*T]int)
+	}
+	cl.external[unsafe.SliceData(val)] = size
+	return val
+}
+
+// isExternal 返回區塊 c 是否為此管道向 allocator 取得的整個區塊
+func (cl *ChunkPipe[T]) isExternal(c offset[T]) bool {
+	if len(cl.external) == 0 || c.cap == 0 {
+		return false
+	}
+	_, ok := cl.external[unsafe.SliceData(chunkBase(c))]
+	return ok
+}
+
+// freeExternal 在 base 完整涵蓋向 allocator 取得的某個區塊時將其歸還並返回 true，呼叫者需持有寫鎖。
+// 有 pin 住的視圖時改為暫存，視圖結束後才處理
+func (cl *ChunkPipe[T]) freeExternal(base []T) bool {
+	p := unsafe.SliceData(base)
+	size, ok := cl.external[p]
+	if !ok || cap(base)*int(unsafe.Sizeof(*new(T))) != size {
+		return false
+	}
+	// 仍可能被 pin 住的視圖引用，等視圖結束後再由 reuse 處理
+	if cl.pinned > 0 {
+		cl.parked = append(cl.parked, base)
+		return true
+	}
+	delete(cl.external, p)
+	cl.allocator.Free(unsafe.Pointer(p), size)
+	return true
+}
+
+// freeChunk 在自有區塊 c 由 allocator 配置時將其歸還並返回 true，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) freeChunk(c offset[T]) bool {
+	if !cl.isExternal(c) {
+		return false
+	}
+	return cl.freeExternal(chunkBase(c))
+}
+
+// toHeap 在切開或共用第 i 個區塊前，將外部配置的區塊複製到堆積上並歸還，呼叫者需持有寫鎖。
+// 外部區塊只在整塊取空時才能歸還，切開或共用後的片段無法判斷何時全部不再使用
+func (cl *ChunkPipe[T]) toHeap(i int) {
+	c := &cl.list[i]
+	if !cl.isExternal(*c) {
+		return
+	}
+	base := chunkBase(*c)
+	val := append(make([]T, 0, len(c.val)), c.val...)
+	cl.freeExternal(base)
+	c.val, c.cap, c.dead = val, cap(val), 0
+}
+
+// giveExternal 將移到 dst 的自有區塊 chunks 的配置記錄一併交給 dst，之後由 dst 負責歸還，
+// 呼叫者需持有兩者的寫鎖
+func (cl *ChunkPipe[T]) giveExternal(dst *ChunkPipe[T], chunks []offset[T]) {
+	if len(cl.external) == 0 {
+		return
+	}
+	for _, c := range chunks {
+		if !cl.isExternal(c) {
+			continue
+		}
+		p := unsafe.SliceData(chunkBase(c))
+		if dst.external == nil {
+			dst.external = make(map[*T]int)
+		}
+		dst.external[p] = cl.external[p]
+		delete(cl.external, p)
+	}
+}
+
+// releaseFree 將空閒列表中外部配置的區塊交還給 allocator，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) releaseFree() {
+	if len(cl.external) == 0 {
+		return
+	}
+	free := cl.free[:0]
+	for _, val := range cl.free {
+		if !cl.freeExternal(val) {
+			free = append(free, val)
+		}
+	}
+	clear(cl.free[len(free):])
+	cl.free = free
+}
@@ -0,0 +1,92 @@
+package chunkpipe
+
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+// ErrBinaryLength 表示待解碼資料的長度不是元素大小的整數倍
+var ErrBinaryLength = errors.New("chunkpipe: binary data length is not a multiple of the element size")
+
+// FixedNumber 為具有固定位元組寬度、可直接以 encoding/binary 編碼的數值型別
+type FixedNumber interface {
+	~int8 | ~uint8 | ~int16 | ~uint16 | ~int32 | ~uint32 |
+		~int64 | ~uint64 | ~float32 | ~float64
+}
+
+// AppendBinary 依 order 將所有元素編碼後追加到 dst 並返回結果
+func AppendBinary[T FixedNumber](cl *ChunkPipe[T], dst []byte, order binary.ByteOrder) []byte {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	dst = growBytes(dst, cl.len()*size)
+
+	for i := range cl.list {
+		for _, v := range cl.list[i].val {
+			p := unsafe.Pointer(&v)
+			n := len(dst)
+			dst = dst[:n+size]
+			switch size {
+			case 1:
+				dst[n] = *(*uint8)(p)
+			case 2:
+				order.PutUint16(dst[n:], *(*uint16)(p))
+			case 4:
+				order.PutUint32(dst[n:], *(*uint32)(p))
+			case 8:
+				order.PutUint64(dst[n:], *(*uint64)(p))
+			}
+		}
+	}
+	return dst
+}
+
+// DecodeBinary 依 order 將 data 解碼為元素並建立新的 ChunkPipe，
+// data 長度必須是元素大小的整數倍
+func DecodeBinary[T FixedNumber](data []byte, order binary.ByteOrder) (*ChunkPipe[T], error) {
+	var zero T
+	size := int(unsafe.Sizeof(zero))
+	if len(data)%size != 0 {
+		return nil, ErrBinaryLength
+	}
+
+	cl := New[T]()
+	if len(data) == 0 {
+		return cl, nil
+	}
+
+	val := make([]T, len(data)/size)
+	for i := range val {
+		p := unsafe.Pointer(&val[i])
+		b := data[i*size:]
+		switch size {
+		case 1:
+			*(*uint8)(p) = b[0]
+		case 2:
+			*(*uint16)(p) = order.Uint16(b)
+		case 4:
+			*(*uint32)(p) = order.Uint32(b)
+		case 8:
+			*(*uint64)(p) = order.Uint64(b)
+		}
+	}
+	cl.list = append(cl.list, offset[T]{
+		val: val,
+		off: len(val),
+		cap: len(val),
+	})
+	return cl, nil
+}
+
+// growBytes 確保 b 至少還有 n 個位元組的剩餘容量
+func growBytes(b []byte, n int) []byte {
+	if cap(b)-len(b) >= n {
+		return b
+	}
+	grown := make([]byte, len(b), len(b)+n)
+	copy(grown, b)
+	return grown
+}
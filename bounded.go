@@ -0,0 +1,170 @@
+package chunkpipe
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrFull 表示有上限的管道沒有足夠空間容納推入的資料
+var ErrFull = errors.New("chunkpipe: pipe is full")
+
+// OverflowPolicy 決定有上限的管道已滿時如何處理推入，見 WithOverflow
+type OverflowPolicy int
+
+const (
+	// OverflowBlock 阻塞到消費者彈出足夠的元素，為預設的策略
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest 從另一端移除最舊的元素騰出空間，管道只保留最後 maxLen 個元素
+	OverflowDropOldest
+	// OverflowDropNewest 只推入容納得下的部分，其餘新的元素直接捨棄
+	OverflowDropNewest
+	// OverflowError 空間不足時整批不推入；TryPush 與 Write 返回 ErrFull，Push 等方法則直接捨棄
+	OverflowError
+)
+
+// NewBounded 建立一個最多容納 maxElems 個元素的管道，opts 與 New 相同。
+// 管道已滿時 Push 會阻塞，直到消費者彈出元素；不想阻塞時使用 TryPush 或 WithOverflow。
+// 上限只限制推入，Replace 等就地修改不受限制。maxElems 小於等於 0 時不設上限
+func NewBounded[T any](maxElems int, opts ...Option) *ChunkPipe[T] {
+	return New[T](append([]Option{WithMaxLen(maxElems)}, opts...)...)
+}
+
+// NewRing 建立一個只保留最後 n 個元素的滑動視窗管道，已滿時推入會移除最舊的元素而不阻塞，
+// 等同以 WithOverflow(OverflowDropOldest) 呼叫 NewBounded，適合保存最近的遙測或事件紀錄
+func NewRing[T any](n int, opts ...Option) *ChunkPipe[T] {
+	return NewBounded[T](n, append([]Option{WithOverflow(OverflowDropOldest)}, opts...)...)
+}
+
+// WithOverflow 設定有上限的管道已滿時推入的處理方式，預設為 OverflowBlock。
+// 除了 OverflowBlock 以外的策略都不會阻塞推入者；沒有上限的管道不受影響
+func WithOverflow(p OverflowPolicy) Option {
+	return func(o *options) {
+		o.overflow = p
+	}
+}
+
+// TryPush 在空間足夠時推入 data，否則不推入任何元素並返回 ErrFull；
+// 管道已關閉時返回 ErrClosed。沒有上限且未關閉的管道總是推入成功，
+// 以 OverflowDropOldest 建立的管道則移除最舊的元素後推入
+func (cl *ChunkPipe[T]) TryPush(data []T) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	if !cl.lockPush() {
+		return ErrClosed
+	}
+	if cl.maxLen > 0 && cl.len()+len(data) > cl.maxLen {
+		if cl.overflow == OverflowDropOldest {
+			_, err := cl.pushOverflow(data, cl.push, false)
+			return err
+		}
+		cl.mu.Unlock()
+		return ErrFull
+	}
+	wasEmpty := len(cl.list) == 0
+	cl.push(data)
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(len(data), 0)
+	return nil
+}
+
+// pushBounded 以 push 分段推入 data，每次只推入剩餘空間容納得下的部分，
+// 管道已滿時等待元素被移除。front 為 true 時從 data 的尾端開始分段，
+// 讓推入頭部的分段依序接起來。分段之間其他推入者的資料可能穿插其中。
+// 返回已推入的數量，管道在推入完成前關閉時返回 ErrClosed；
+// 設定了 OverflowBlock 以外的策略時改由 pushOverflow 處理而不等待
+func (cl *ChunkPipe[T]) pushBounded(data []T, push func([]T), front bool) (int, error) {
+	total := 0
+	for len(data) > 0 {
+		if !cl.lockPush() {
+			return total, ErrClosed
+		}
+		if cl.overflow != OverflowBlock {
+			return cl.pushOverflow(data, push, front)
+		}
+		n := min(len(data), cl.maxLen-cl.len())
+		if n <= 0 {
+			if cl.space == nil {
+				cl.space = make(chan struct{})
+			}
+			space := cl.space
+			cl.mu.Unlock()
+			start := time.Now()
+			<-space
+			cl.blocked(start)
+			continue
+		}
+
+		wasEmpty := len(cl.list) == 0
+		// 限制分段的容量，避免自有區塊的剩餘空間覆寫尚未推入的部分
+		if front {
+			push(data[len(data)-n:])
+			data = data[: len(data)-n : len(data)-n]
+		} else {
+			push(data[:n:n])
+			data = data[n:]
+		}
+		if wasEmpty {
+			cl.signal()
+		}
+		cl.unlock(n, 0)
+		total += n
+	}
+	return total, nil
+}
+
+// pushOverflow 依 overflow 策略推入 data 而不等待，呼叫者需持有寫鎖，返回時已釋放。
+// 返回推入的數量；OverflowDropOldest 一律視為全部推入，其他策略捨棄元素時返回 ErrFull
+func (cl *ChunkPipe[T]) pushOverflow(data []T, push func([]T), front bool) (int, error) {
+	accepted, dropped := len(data), 0
+	var err error
+	if room := max(cl.maxLen-cl.len(), 0); len(data) > room {
+		switch cl.overflow {
+		case OverflowDropOldest:
+			// 超過上限的部分推入後也會立即被移除，直接只保留靠近另一端的 maxLen 個
+			var over []T
+			if len(data) > cl.maxLen {
+				if front {
+					data, over = data[:cl.maxLen], data[cl.maxLen:]
+				} else {
+					over, data = data[:len(data)-cl.maxLen], data[len(data)-cl.maxLen:]
+				}
+			}
+			dropped = len(data) - room
+			if front {
+				cl.evict(cl.offset+cl.len()-dropped, dropped)
+				cl.discardEnd(dropped)
+			} else {
+				cl.evict(cl.offset, dropped)
+				cl.discardFront(dropped)
+			}
+			cl.evictData(over)
+		case OverflowDropNewest:
+			if front {
+				cl.evictData(data[:len(data)-room])
+				data = data[len(data)-room:]
+			} else {
+				cl.evictData(data[room:])
+				data = data[:room]
+			}
+			accepted, err = room, ErrFull
+		default:
+			cl.evictData(data)
+			data, accepted, err = nil, 0, ErrFull
+		}
+	}
+
+	wasEmpty := len(cl.list) == 0
+	if len(data) > 0 {
+		// 限制容量，避免自有區塊的剩餘空間覆寫被捨棄的部分
+		push(data[:len(data):len(data)])
+		if wasEmpty {
+			cl.signal()
+		}
+	}
+	cl.unlock(len(data), dropped)
+	return accepted, err
+}
@@ -0,0 +1,259 @@
+package chunkpipe
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"slices"
+	"time"
+	"unsafe"
+)
+
+// AppendReader 以 chunkSize 位元組為單位從 r 讀取資料，每個區塊直接成為管道的數據塊，
+// 直到 io.EOF 為止，返回追加的位元組數。讀取發生其他錯誤時，
+// 已讀到的資料仍會保留在管道中並返回該錯誤；管道關閉後停止並返回 ErrClosed。
+// chunkSize 小於等於 0 時使用管道的區塊大小
+func AppendReader(cl *ChunkPipe[byte], r io.Reader, chunkSize int) (int, error) {
+	if chunkSize <= 0 {
+		chunkSize = cl.chunkSize
+	}
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	total := 0
+	for {
+		buf := make([]byte, chunkSize)
+		n, err := io.ReadFull(r, buf)
+		if n > 0 && cl.maxLen > 0 {
+			pushed, err := cl.pushBounded(buf[:n], cl.pushOwned, false)
+			total += pushed
+			if err != nil {
+				return total, err
+			}
+		} else if n > 0 {
+			if !cl.lockPush() {
+				return total, ErrClosed
+			}
+			wasEmpty := len(cl.list) == 0
+			cl.pushOwned(buf[:n])
+			if wasEmpty {
+				cl.signal()
+			}
+			cl.unlock(n, 0)
+			total += n
+		}
+
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			return total, nil
+		default:
+			return total, err
+		}
+	}
+}
+
+// ByteChunkPipe 是 ChunkPipe[byte] 的包裝，提供組合文字與位元組串流的便利方法。
+// 寫入的資料一律複製，並依管道的區塊大小追加到尾端區塊
+type ByteChunkPipe struct {
+	*ChunkPipe[byte]
+}
+
+// NewByteChunkPipe 建立一個 ByteChunkPipe，opts 與 New 相同
+func NewByteChunkPipe(opts ...Option) *ByteChunkPipe {
+	return &ByteChunkPipe{New[byte](opts...)}
+}
+
+// write 將 p 複製到尾端，返回寫入的數量；管道關閉後返回 ErrClosed
+func (b *ByteChunkPipe) write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	cl := b.ChunkPipe
+	if cl.maxLen > 0 {
+		return cl.pushBounded(p, cl.pushCopy, false)
+	}
+	if !cl.lockPush() {
+		return 0, ErrClosed
+	}
+	wasEmpty := len(cl.list) == 0
+	cl.pushCopy(p)
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(len(p), 0)
+	return len(p), nil
+}
+
+// Write 實作 io.Writer，管道未關閉時總是寫入全部的 p
+func (b *ByteChunkPipe) Write(p []byte) (int, error) {
+	return b.write(p)
+}
+
+// Read 實作 io.Reader，從頭部取出最多 len(p) 個位元組；管道為空時阻塞直到有資料
+// 或被關閉，關閉且讀完剩餘的資料後才返回 io.EOF
+func (b *ByteChunkPipe) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for {
+		if n := b.PopFrontInto(p); n > 0 {
+			return n, nil
+		}
+		if !b.wait() {
+			return 0, io.EOF
+		}
+	}
+}
+
+// wait 阻塞直到管道有資料，管道已關閉且為空時返回 false
+func (b *ByteChunkPipe) wait() bool {
+	cl := b.ChunkPipe
+	for {
+		cl.mu.Lock()
+		if cl.len() > 0 {
+			cl.mu.Unlock()
+			return true
+		}
+		if cl.closed {
+			cl.mu.Unlock()
+			return false
+		}
+		if cl.ready == nil {
+			cl.ready = make(chan struct{})
+		}
+		ready := cl.ready
+		cl.mu.Unlock()
+
+		start := time.Now()
+		<-ready
+		cl.blocked(start)
+	}
+}
+
+// WriteTo 實作 io.WriterTo，依序將頭部區塊直接寫入 w 並移除已寫出的部分，
+// 不會先合併成一個大切片。與 Read 相同，管道為空時阻塞直到有資料或被關閉，
+// 關閉且寫完剩餘的資料後才返回。區塊在鎖外寫出，寫出期間被其他消費者取走的部分不會重複移除，
+// PushFront、Insert 等修改也不會讓尚未寫出的資料被移除
+func (b *ByteChunkPipe) WriteTo(w io.Writer) (int64, error) {
+	cl := b.ChunkPipe
+	var total int64
+	for {
+		cl.mu.Lock()
+		if len(cl.list) == 0 {
+			cl.mu.Unlock()
+			if !b.wait() {
+				return total, nil
+			}
+			continue
+		}
+		val := cl.list[0].val
+		view := val[:len(val):len(val)]
+		// 寫出期間區塊被其他消費者取空時，不能被之後的推入重複使用
+		cl.pin()
+		cl.mu.Unlock()
+
+		n, err := w.Write(view)
+		cl.mu.Lock()
+		done := b.dropWritten(view[:n])
+		cl.unpin()
+		cl.unlock(0, done)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		if n < len(view) {
+			return total, io.ErrShortWrite
+		}
+	}
+}
+
+// dropWritten 移除仍保存在 written 記憶體中的元素，返回移除的數量，呼叫者需持有寫鎖。
+// written 為 pin 住的視圖，期間的 PushFront、Insert 等修改會改變元素的位置，
+// 因此以記憶體位置而非偏移判斷哪些元素已經寫出；已被其他消費者取走的部分不會重複移除
+func (b *ByteChunkPipe) dropWritten(written []byte) int {
+	cl := b.ChunkPipe
+	if len(written) == 0 {
+		return 0
+	}
+	lo := uintptr(unsafe.Pointer(unsafe.SliceData(written)))
+	hi := lo + uintptr(len(written))
+	// 依絕對位置記錄仍在管道中的已寫出範圍
+	var spans [][2]int
+	for _, c := range cl.list {
+		if len(c.val) == 0 {
+			continue
+		}
+		p := uintptr(unsafe.Pointer(unsafe.SliceData(c.val)))
+		from, to := max(p, lo), min(p+uintptr(len(c.val)), hi)
+		if from < to {
+			start := c.off - len(c.val) + int(from-p)
+			spans = append(spans, [2]int{start, start + int(to-from)})
+		}
+	}
+
+	done := 0
+	// 由後往前移除，前面範圍的絕對位置不受影響
+	for k := len(spans) - 1; k >= 0; k-- {
+		start, end := spans[k][0], spans[k][1]
+		done += end - start
+		if start == cl.offset {
+			cl.discardFront(end - start)
+			continue
+		}
+		cl.shiftStamps(start, end-start, 0)
+		i := cl.splitAt(start)
+		j := cl.splitAt(end)
+		for _, c := range cl.list[i:j] {
+			cl.clearSlots(c, c.val)
+		}
+		cl.list = slices.Delete(cl.list, i, j)
+		cl.reindex(i)
+	}
+	return done
+}
+
+// ReadFrom 實作 io.ReaderFrom，以管道的區塊大小讀入自有區塊直到 io.EOF，見 AppendReader
+func (b *ByteChunkPipe) ReadFrom(r io.Reader) (int64, error) {
+	n, err := AppendReader(b.ChunkPipe, r, 0)
+	return int64(n), err
+}
+
+// WriteString 實作 io.StringWriter，不會先將 s 轉換為 []byte
+func (b *ByteChunkPipe) WriteString(s string) (int, error) {
+	return b.write(unsafe.Slice(unsafe.StringData(s), len(s)))
+}
+
+// WriteByte 實作 io.ByteWriter
+func (b *ByteChunkPipe) WriteByte(c byte) error {
+	_, err := b.write([]byte{c})
+	return err
+}
+
+// Printf 依 format 格式化後追加到尾端，返回寫入的位元組數
+func (b *ByteChunkPipe) Printf(format string, args ...any) (int, error) {
+	return fmt.Fprintf(b, format, args...)
+}
+
+// Bytes 返回目前所有位元組的副本
+func (b *ByteChunkPipe) Bytes() []byte {
+	return b.ValueSlice()
+}
+
+// Hash 將目前所有位元組依序寫入 h 並返回 h.Sum64()，結果與數據塊的切分方式無關
+func (b *ByteChunkPipe) Hash(h hash.Hash64) uint64 {
+	b.mu.RLock()
+	for i := range b.list {
+		h.Write(b.list[i].val)
+	}
+	b.mu.RUnlock()
+	return h.Sum64()
+}
+
+// Sum64 返回目前內容的 FNV-1a 64 位元雜湊，可作為快取鍵或去重使用
+func (b *ByteChunkPipe) Sum64() uint64 {
+	return b.Hash(fnv.New64a())
+}
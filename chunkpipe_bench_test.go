@@ -2,6 +2,7 @@ package chunkpipe
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 )
 
@@ -309,3 +310,230 @@ func BenchmarkMemoryUsage(b *testing.B) {
 		})
 	}
 }
+
+// 基準測試：8 個 goroutine 並發 Push
+func benchmarkConcurrentPush(b *testing.B, push func([]int)) {
+	const goroutines = 8
+	data := []int{1, 2, 3, 4}
+	b.ResetTimer()
+	var wg sync.WaitGroup
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < b.N/goroutines; i++ {
+				push(data)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkConcurrentPush(b *testing.B) {
+	b.Run("ChunkPipe", func(b *testing.B) {
+		cp := NewChunkPipe[int]()
+		benchmarkConcurrentPush(b, func(data []int) { cp.Push(data) })
+	})
+
+	b.Run("Sharded-8", func(b *testing.B) {
+		sp := NewSharded[int](8)
+		benchmarkConcurrentPush(b, func(data []int) { sp.Push(data) })
+	})
+}
+
+// 基準測試：單一生產者與單一消費者
+func BenchmarkSPSC(b *testing.B) {
+	data := make([]int, 16)
+
+	b.Run("ChunkPipe", func(b *testing.B) {
+		cp := NewChunkPipe[int]()
+		go func() {
+			for i := 0; i < b.N; i += len(data) {
+				cp.Push(data)
+			}
+		}()
+		for i := 0; i < b.N/len(data)*len(data); {
+			if _, ok := cp.PopFront(); ok {
+				i++
+			}
+		}
+	})
+
+	b.Run("SPSCChunkPipe", func(b *testing.B) {
+		p := NewSPSC[int](defaultChunkSize)
+		go func() {
+			for i := 0; i < b.N; i += len(data) {
+				p.Push(data)
+			}
+		}()
+		for i := 0; i < b.N/len(data)*len(data); {
+			if _, ok := p.PopFront(); ok {
+				i++
+			}
+		}
+	})
+}
+
+// 基準測試：逐一 Push 與 PushAll 的鎖開銷比較
+func BenchmarkPushAll(b *testing.B) {
+	segments := make([][]int, 64)
+	for i := range segments {
+		segments[i] = make([]int, 16)
+	}
+
+	b.Run("Push", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cp := NewChunkPipe[int]()
+			for _, seg := range segments {
+				cp.Push(seg)
+			}
+		}
+	})
+
+	b.Run("PushAll", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewChunkPipe[int]().PushAll(segments...)
+		}
+	})
+}
+
+type benchStruct64 struct {
+	a, b, c, d, e, f, g, h int64
+}
+
+func benchmarkRangeValuesN[T any](b *testing.B, v T) {
+	for _, batch := range []int{1, 4, 16, 64, 256} {
+		b.Run(fmt.Sprintf("Batch-%d", batch), func(b *testing.B) {
+			cp := Repeat(v, 10000)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cp.RangeValuesN(batch, func(T) bool { return true })
+			}
+		})
+	}
+}
+
+// 基準測試：不同批次大小下 RangeValuesN 的走訪吞吐量
+func BenchmarkRangeValuesN(b *testing.B) {
+	b.Run("Int", func(b *testing.B) { benchmarkRangeValuesN(b, 1) })
+	b.Run("Struct64", func(b *testing.B) { benchmarkRangeValuesN(b, benchStruct64{}) })
+	b.Run("String", func(b *testing.B) { benchmarkRangeValuesN(b, "chunkpipe") })
+}
+
+// 基準測試：大量區塊時索引二分搜尋與線性掃描的 Get 比較
+func BenchmarkGetManyChunks(b *testing.B) {
+	for _, chunks := range []int{100, 10000} {
+		for _, tree := range []bool{true, false} {
+			b.Run(fmt.Sprintf("Chunks-%d/Index-%v", chunks, tree), func(b *testing.B) {
+				cp := New[int](WithCopyOnPush(false), WithTree(tree))
+				for i := 0; i < chunks; i++ {
+					cp.Push(make([]int, 16))
+				}
+				n := chunks * 16
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					cp.Get(i * 7919 % n)
+				}
+			})
+		}
+	}
+}
+
+// 基準測試：單一 goroutine 下啟用與停用內部鎖的 Push/PopFront 開銷
+func BenchmarkNoLock(b *testing.B) {
+	for _, noLock := range []bool{false, true} {
+		b.Run(fmt.Sprintf("NoLock-%v", noLock), func(b *testing.B) {
+			var opts []Option
+			if noLock {
+				opts = append(opts, WithNoLock())
+			}
+			cp := New[int](opts...)
+			data := []int{1, 2, 3}
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				cp.Push(data)
+				cp.PopFront()
+			}
+		})
+	}
+}
+
+// 基準測試：逐一推入單一元素
+func BenchmarkPushOne(b *testing.B) {
+	b.Run("Push", func(b *testing.B) {
+		b.ReportAllocs()
+		cp := NewChunkPipe[int]()
+		for i := 0; i < b.N; i++ {
+			cp.Push([]int{i})
+		}
+	})
+
+	b.Run("PushOne", func(b *testing.B) {
+		b.ReportAllocs()
+		cp := NewChunkPipe[int]()
+		for i := 0; i < b.N; i++ {
+			cp.PushOne(i)
+		}
+	})
+}
+
+// 基準測試：逐一 PopFront 與 Drain 取出所有元素
+func BenchmarkDrain(b *testing.B) {
+	data := make([]int, 4096)
+
+	b.Run("PopFront", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			cp := NewChunkPipe[int]().Push(data)
+			for {
+				if _, ok := cp.PopFront(); !ok {
+					break
+				}
+			}
+		}
+	})
+
+	b.Run("Drain", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			NewChunkPipe[int]().Push(data).Drain(func(int) {})
+		}
+	})
+}
+
+// 基準測試：逐一 Get 與一次 GetRange 讀取連續範圍
+func BenchmarkGetRange(b *testing.B) {
+	cp := New[int](WithChunkSize(64))
+	for i := 0; i < 1<<14; i++ {
+		cp.PushOne(i)
+	}
+	buf := make([]int, 0, 1024)
+
+	b.Run("Get", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf = buf[:0]
+			for j := 4096; j < 4096+1024; j++ {
+				v, _ := cp.Get(j)
+				buf = append(buf, v)
+			}
+		}
+	})
+
+	b.Run("GetRange", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			buf, _ = cp.GetRange(4096, 1024, buf[:0])
+		}
+	})
+}
+
+// 基準測試：穩定狀態的佇列，取空的區塊放回池中供之後的推入重複使用
+func BenchmarkQueueSteadyState(b *testing.B) {
+	cp := New[int](WithChunkSize(256))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1024; j++ {
+			cp.PushOne(j)
+		}
+		for j := 0; j < 1024; j++ {
+			cp.PopFront()
+		}
+	}
+}
@@ -1,9 +1,26 @@
 package chunkpipe
 
 import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
+	"time"
+	"unsafe"
 )
 
 // 測試不同類型的數據結構
@@ -91,8 +108,8 @@ func TestEdgeCases(t *testing.T) {
 		cp := NewChunkPipe[byte]()
 		data := make([]byte, 1000000)
 		cp.Push(data)
-		if cp.size() != 1000000 {
-			t.Errorf("Expected size 1000000, got %d", cp.size())
+		if cp.Len() != 1000000 {
+			t.Errorf("Expected size 1000000, got %d", cp.Len())
 		}
 	})
 
@@ -146,8 +163,8 @@ func TestLargeDataHandling(t *testing.T) {
 			cp.Push(data)
 
 			// 驗證大小
-			if cp.size() != size {
-				t.Errorf("Expected size %d, got %d", size, cp.size())
+			if cp.Len() != size {
+				t.Errorf("Expected size %d, got %d", size, cp.Len())
 			}
 
 			// 測試讀取
@@ -209,8 +226,8 @@ func TestPublicMethods(t *testing.T) {
 		cp := NewChunkPipe[int]()
 		data := []int{1, 2, 3}
 		cp.Push(data)
-		if cp.size() != 3 {
-			t.Errorf("Push failed: expected size 3, got %d", cp.size())
+		if cp.Len() != 3 {
+			t.Errorf("Push failed: expected size 3, got %d", cp.Len())
 		}
 	})
 
@@ -371,3 +388,4829 @@ func TestChunkIteratorEdgeCases(t *testing.T) {
 		}
 	})
 }
+
+func TestSmallPushCapacity(t *testing.T) {
+	t.Run("ManySmallSlices", func(t *testing.T) {
+		cp := NewChunkPipe[byte]()
+		var want []byte
+		for i := 0; i < 1000; i++ {
+			data := []byte{byte(i), byte(i >> 8), byte(i * 3)}
+			cp.Push(data)
+			want = append(want, data...)
+			// 推入後修改來源切片不應影響管道內容
+			data[0] = 0xff
+		}
+		got := cp.ValueSlice()
+		if len(got) != len(want) {
+			t.Fatalf("length = %d, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("value at %d = %v, want %v", i, got[i], want[i])
+			}
+		}
+	})
+
+	t.Run("BorrowedSpareCapacity", func(t *testing.T) {
+		cp := New[byte](WithCopyOnPush(false))
+		backing := make([]byte, 64)
+		for i := range backing {
+			backing[i] = 0xaa
+		}
+		large := backing[:16]
+		cp.Push(large)
+		cp.Push([]byte{1, 2, 3})
+		for i := 16; i < len(backing); i++ {
+			if backing[i] != 0xaa {
+				t.Fatalf("caller memory at %d overwritten: %v", i, backing[i])
+			}
+		}
+		if v, ok := cp.Get(16); !ok || v != 1 {
+			t.Errorf("Get(16) = %v, %v, want 1, true", v, ok)
+		}
+	})
+
+	t.Run("SplitOwnedTail", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		cp.Push(make([]int, 20))
+		// 切分後左右兩半共用同一個自有陣列
+		cp.Replace(5, 5, []int{9})
+		right, _ := cp.PopChunkEnd()
+		cp.PopChunkEnd()
+		if cp.NumChunks() != 1 || cp.Len() != 5 {
+			t.Fatalf("expected only the left half, got %v", cp.ChunkLens())
+		}
+		// 左半部成為尾端後，小量推入不能寫進已交給呼叫者的右半部
+		cp.Push([]int{1, 2, 3})
+		for i, v := range right {
+			if v != 0 {
+				t.Fatalf("popped chunk overwritten at %d: %d", i, v)
+			}
+		}
+		if got := cp.ValueSlice(); !slices.Equal(got, []int{0, 0, 0, 0, 0, 1, 2, 3}) {
+			t.Errorf("ValueSlice() = %v", got)
+		}
+	})
+
+	t.Run("ChunkViewAppend", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		cp.Push([]int{1, 2})
+		chunk := cp.ChunkSlice()[0]
+		_ = append(chunk, 99)
+		cp.Push([]int{3})
+		if v, ok := cp.Get(2); !ok || v != 3 {
+			t.Errorf("Get(2) = %v, %v, want 3, true", v, ok)
+		}
+	})
+
+	t.Run("PopFrontThenAppend", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		want := []int{}
+		for i := 0; i < defaultChunkSize; i++ {
+			cp.Push([]int{i})
+			want = append(want, i)
+			if i%3 == 0 {
+				if v, ok := cp.PopFront(); !ok || v != want[0] {
+					t.Fatalf("PopFront = %v, %v, want %v, true", v, ok, want[0])
+				}
+				want = want[1:]
+			}
+		}
+		got := cp.ValueSlice()
+		if len(got) != len(want) {
+			t.Fatalf("length = %d, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("value at %d = %v, want %v", i, got[i], want[i])
+			}
+		}
+	})
+}
+
+func TestNewWithOptions(t *testing.T) {
+	t.Run("InitialCapacity", func(t *testing.T) {
+		cp := New[int](WithInitialCapacity(100))
+		cp.Push([]int{1, 2, 3})
+		if len(cp.list) != 1 {
+			t.Fatalf("chunks = %d, want 1", len(cp.list))
+		}
+		head := cp.list[0]
+		if head.cap != 100 || cap(head.val)-len(head.val) != 97 {
+			t.Errorf("head chunk cap = %d, spare = %d, want 100, 97",
+				head.cap, cap(head.val)-len(head.val))
+		}
+	})
+
+	t.Run("ChunkSize", func(t *testing.T) {
+		cp := New[int](WithChunkSize(4))
+		for i := 0; i < 10; i++ {
+			cp.Push([]int{i})
+		}
+		if len(cp.list) != 3 {
+			t.Errorf("chunks = %d, want 3", len(cp.list))
+		}
+		for i := 0; i < 10; i++ {
+			if v, ok := cp.Get(i); !ok || v != i {
+				t.Errorf("Get(%d) = %v, %v, want %d, true", i, v, ok, i)
+			}
+		}
+	})
+
+	t.Run("WithoutTree", func(t *testing.T) {
+		cp := New[int](WithTree(false))
+		for i := 0; i < 10; i++ {
+			cp.Push(make([]int, 10))
+		}
+		cp.PopFront()
+		cp.Push([]int{42})
+		if v, ok := cp.Get(99); !ok || v != 42 {
+			t.Errorf("Get(99) = %v, %v, want 42, true", v, ok)
+		}
+		if _, ok := cp.Get(100); ok {
+			t.Error("Get should return false for out of range index")
+		}
+	})
+
+	t.Run("MaxLen", func(t *testing.T) {
+		cp := New[int](WithMaxLen(3))
+		if err := cp.TryPush([]int{1, 2, 3, 4}); !errors.Is(err, ErrFull) {
+			t.Errorf("TryPush over WithMaxLen = %v, want ErrFull", err)
+		}
+		if err := cp.TryPush([]int{1, 2, 3}); err != nil {
+			t.Errorf("TryPush within WithMaxLen = %v", err)
+		}
+		if cp.newLike().maxLen != 3 {
+			t.Error("newLike should keep the element limit")
+		}
+	})
+
+	t.Run("NoLock", func(t *testing.T) {
+		cp := New[int](WithNoLock())
+		for i := 0; i < 100; i++ {
+			cp.Push([]int{i})
+		}
+		cp.PopFront()
+		cp.PopEnd()
+		if v, ok := cp.Get(10); !ok || v != 11 {
+			t.Errorf("Get(10) = %v, %v, want 11, true", v, ok)
+		}
+		// 鎖被停用時巢狀加鎖不會死鎖
+		cp.mu.Lock()
+		cp.mu.Lock()
+		cp.mu.Unlock()
+		cp.mu.Unlock()
+		if !cp.newLike().mu.disabled {
+			t.Error("newLike should keep the lock disabled")
+		}
+		assertInvariants(t, cp, 0)
+	})
+}
+
+func TestSnapshot(t *testing.T) {
+	t.Run("Isolation", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		cp.Push([]int{1, 2, 3})
+		cp.Push([]int{4})
+		snap := cp.Snapshot()
+
+		cp.PopEnd()
+		cp.Push([]int{99})
+		cp.PopFront()
+
+		if snap.Len() != 4 {
+			t.Fatalf("Len = %d, want 4", snap.Len())
+		}
+		for i := 0; i < 4; i++ {
+			if v, ok := snap.Get(i); !ok || v != i+1 {
+				t.Errorf("Get(%d) = %v, %v, want %d, true", i, v, ok, i+1)
+			}
+		}
+		if _, ok := snap.Get(4); ok {
+			t.Error("Get should return false for out of range index")
+		}
+
+		count := 0
+		snap.Range(func(i, v int) bool {
+			count++
+			return i < 1
+		})
+		if count != 2 {
+			t.Errorf("Range visited %d elements, want 2", count)
+		}
+	})
+
+	t.Run("ConcurrentMutation", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		for i := 0; i < 100; i++ {
+			cp.Push([]int{i, i})
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cp.Push([]int{i})
+				cp.PopEnd()
+				cp.PopFront()
+				cp.Push([]int{i, i})
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				snap := cp.Snapshot()
+				n := snap.Len()
+				sum := 0
+				snap.Range(func(_ int, v int) bool {
+					sum += v
+					return true
+				})
+				if snap.Len() != n {
+					t.Errorf("snapshot length changed from %d to %d", n, snap.Len())
+				}
+			}
+		}()
+		wg.Wait()
+	})
+}
+
+func TestShardedChunkPipe(t *testing.T) {
+	sp := NewSharded[int](4)
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				sp.Push([]int{g*100 + i})
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if sp.Len() != 800 {
+		t.Fatalf("Len = %d, want 800", sp.Len())
+	}
+
+	seen := make(map[int]bool)
+	sp.Range(func(v int) bool {
+		seen[v] = true
+		return true
+	})
+	if len(seen) != 800 {
+		t.Errorf("Range saw %d distinct values, want 800", len(seen))
+	}
+
+	count := 0
+	sp.Drain(func(v int) {
+		if !seen[v] {
+			t.Errorf("Drain returned unexpected value %d", v)
+		}
+		count++
+	})
+	if count != 800 || sp.Len() != 0 {
+		t.Errorf("Drain returned %d values, Len = %d, want 800, 0", count, sp.Len())
+	}
+}
+
+func TestShardedChunkPipeOrder(t *testing.T) {
+	sp := NewSharded[int](2)
+	for i := 0; i < 6; i++ {
+		sp.Push([]int{i})
+	}
+	var got []int
+	sp.Range(func(v int) bool {
+		got = append(got, v)
+		return len(got) < 4
+	})
+	want := []int{0, 2, 4, 1}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("Range = %v, want %v", got, want)
+	}
+}
+
+func TestPopEndAfterFrontConsumed(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	cp.Push([]int{1, 2})
+	cp.Push([]int{3, 4, 5, 6, 7, 8, 9, 10, 11})
+
+	// 從頭部把第一個區塊彈空
+	for want := 1; want <= 2; want++ {
+		if v, ok := cp.PopFront(); !ok || v != want {
+			t.Fatalf("PopFront = %v, %v, want %d, true", v, ok, want)
+		}
+	}
+
+	for want := 11; want >= 3; want-- {
+		if v, ok := cp.PopEnd(); !ok || v != want {
+			t.Fatalf("PopEnd = %v, %v, want %d, true", v, ok, want)
+		}
+	}
+	if v, ok := cp.PopEnd(); ok {
+		t.Errorf("PopEnd on empty pipe = %v, true, want false", v)
+	}
+	if n := len(cp.list); n != 0 {
+		t.Errorf("chunks left = %d, want 0", n)
+	}
+}
+
+func TestObserverHooks(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	var pushed, popped []int
+	cp.OnPush(func(n int) {
+		// 回呼在鎖外執行，可以安全地讀取管道
+		_ = cp.Len()
+		pushed = append(pushed, n)
+	}).OnPop(func(n int) {
+		_ = cp.Len()
+		popped = append(popped, n)
+	})
+
+	cp.Push([]int{1, 2, 3})
+	cp.Push(nil)
+	cp.PopFront()
+	cp.PopEnd()
+
+	if fmt.Sprint(pushed) != "[3]" {
+		t.Errorf("push hook calls = %v, want [3]", pushed)
+	}
+	if fmt.Sprint(popped) != "[1 1]" {
+		t.Errorf("pop hook calls = %v, want [1 1]", popped)
+	}
+
+	cp.PopChunkFront()
+	cp.PopFront()
+	if fmt.Sprint(popped) != "[1 1 1]" {
+		t.Errorf("pop hook calls = %v, want [1 1 1]", popped)
+	}
+
+	cp.OnPush(nil).OnPop(nil)
+	cp.Push([]int{4})
+	cp.PopFront()
+	if len(pushed) != 1 || len(popped) != 3 {
+		t.Errorf("hooks fired after being removed: %v, %v", pushed, popped)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	ch := cp.Notify()
+
+	wakeups := func() int {
+		n := 0
+		for {
+			select {
+			case <-ch:
+				n++
+			default:
+				return n
+			}
+		}
+	}
+
+	cp.Push([]int{1})
+	cp.Push([]int{2, 3})
+	if n := wakeups(); n != 1 {
+		t.Errorf("wake-ups after pushes into empty pipe = %d, want 1", n)
+	}
+
+	cp.Push([]int{4})
+	if n := wakeups(); n != 0 {
+		t.Errorf("wake-ups after push into non-empty pipe = %d, want 0", n)
+	}
+
+	for {
+		if _, ok := cp.PopFront(); !ok {
+			break
+		}
+	}
+	cp.Push(nil)
+	cp.Push([]int{5})
+	if n := wakeups(); n != 1 {
+		t.Errorf("wake-ups after refilling drained pipe = %d, want 1", n)
+	}
+
+	if cp.Notify() != ch {
+		t.Error("Notify should return the same channel")
+	}
+}
+
+func TestRepartition(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	for i := 0; i < 50; i++ {
+		cp.Push(make([]int, 9))
+	}
+	cp.PopFront()
+	cp.PopEnd()
+	cp.Push([]int{1, 2, 3})
+	before := cp.ValueSlice()
+
+	cp.Repartition(64)
+	if got := cp.ValueSlice(); fmt.Sprint(got) != fmt.Sprint(before) {
+		t.Fatalf("ValueSlice changed after Repartition")
+	}
+
+	chunks := cp.ChunkSlice()
+	if want := (len(before) + 63) / 64; len(chunks) != want {
+		t.Errorf("chunks = %d, want %d", len(chunks), want)
+	}
+	for i, chunk := range chunks[:len(chunks)-1] {
+		if len(chunk) != 64 {
+			t.Errorf("chunk %d size = %d, want 64", i, len(chunk))
+		}
+	}
+
+	// 重新分區後的索引與彈出仍需正確
+	if v, ok := cp.Get(len(before) - 1); !ok || v != 3 {
+		t.Errorf("Get(last) = %v, %v, want 3, true", v, ok)
+	}
+	if v, ok := cp.PopEnd(); !ok || v != 3 {
+		t.Errorf("PopEnd = %v, %v, want 3, true", v, ok)
+	}
+
+	empty := NewChunkPipe[int]()
+	empty.Repartition(8)
+	if len(empty.ChunkSlice()) != 0 {
+		t.Error("Repartition on empty pipe should not create chunks")
+	}
+}
+
+func TestRepeatAndFillRange(t *testing.T) {
+	cp := Repeat(7, 1000)
+	if cp.Len() != 1000 {
+		t.Fatalf("Repeat(7, 1000) size = %d, want 1000", cp.Len())
+	}
+	if v, ok := cp.Get(999); !ok || v != 7 {
+		t.Errorf("Get(999) = %v, %v, want 7, true", v, ok)
+	}
+	if Repeat("x", 0).Len() != 0 {
+		t.Error("Repeat with count 0 should be empty")
+	}
+
+	cp = NewChunkPipe[int]()
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push([]int{10, 11})
+	cp.Push([]int{12, 13, 14, 15, 16, 17, 18, 19, 20})
+	if !cp.FillRange(8, 13, -1) {
+		t.Fatal("FillRange(8, 13) returned false")
+	}
+	for i, v := range cp.ValueSlice() {
+		want := i
+		if i >= 8 && i < 13 {
+			want = -1
+		}
+		if v != want {
+			t.Errorf("value at %d = %d, want %d", i, v, want)
+		}
+	}
+
+	if cp.FillRange(-1, 2, 0) || cp.FillRange(5, 4, 0) || cp.FillRange(20, 22, 0) {
+		t.Error("FillRange should return false for invalid ranges")
+	}
+	if !cp.FillRange(3, 3, 0) {
+		t.Error("FillRange should accept an empty range")
+	}
+}
+
+func TestChunkAt(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	for i := 0; i < 5; i++ {
+		chunk := make([]int, 10+i)
+		for j := range chunk {
+			chunk[j] = i*100 + j
+		}
+		cp.Push(chunk)
+	}
+	cp.PopFront()
+
+	for i := 0; i < cp.Len(); i++ {
+		slice, pos, ok := cp.ChunkAt(i)
+		want, _ := cp.Get(i)
+		if !ok || slice[pos] != want {
+			t.Fatalf("ChunkAt(%d) = slice[%d], %v, want %v", i, pos, ok, want)
+		}
+	}
+
+	slice, pos, ok := cp.ChunkAt(9)
+	if !ok || pos != 0 || len(slice) != 11 || slice[0] != 100 {
+		t.Errorf("ChunkAt(9) = %v, %d, %v, want second chunk at 0", slice, pos, ok)
+	}
+	if _, _, ok := cp.ChunkAt(cp.Len()); ok {
+		t.Error("ChunkAt should return false for out of range index")
+	}
+}
+
+func TestCursor(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push([]int{10})
+	cp.Push([]int{11, 12, 13, 14, 15, 16, 17, 18, 19})
+
+	t.Run("Sequential", func(t *testing.T) {
+		c := cp.Cursor()
+		for want := 0; want < 20; want++ {
+			if v, ok := c.Next(); !ok || v != want {
+				t.Fatalf("Next = %v, %v, want %d, true", v, ok, want)
+			}
+		}
+		if _, ok := c.Next(); ok {
+			t.Error("Next should return false at the end")
+		}
+		c.Reset()
+		if v, ok := c.Next(); !ok || v != 0 {
+			t.Errorf("Next after Reset = %v, %v, want 0, true", v, ok)
+		}
+	})
+
+	t.Run("Seek", func(t *testing.T) {
+		c := cp.Cursor()
+		c.Seek(9)
+		for want := 9; want < 12; want++ {
+			if v, ok := c.Next(); !ok || v != want {
+				t.Fatalf("Next = %v, %v, want %d, true", v, ok, want)
+			}
+		}
+		c.Seek(20)
+		if _, ok := c.Next(); ok {
+			t.Error("Next after Seek past the end should return false")
+		}
+	})
+
+	t.Run("Mutation", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+		c := cp.Cursor()
+		c.Next()
+		c.Next()
+		for i := 0; i < 5; i++ {
+			cp.PopFront()
+		}
+		cp.Push([]int{10})
+		for want := 5; want <= 10; want++ {
+			if v, ok := c.Next(); !ok || v != want {
+				t.Fatalf("Next = %v, %v, want %d, true", v, ok, want)
+			}
+		}
+	})
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	cp := NewChunkPipe[uint32]()
+	cp.Push([]uint32{1, 0x01020304, 0xffffffff})
+	cp.Push([]uint32{42, 7, 8, 9, 10, 11, 12, 13, 14})
+	cp.PopFront()
+	want := cp.ValueSlice()
+
+	for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+		t.Run(order.String(), func(t *testing.T) {
+			prefix := []byte{0xee}
+			data := AppendBinary(cp, prefix, order)
+			if len(data) != 1+4*len(want) || data[0] != 0xee {
+				t.Fatalf("AppendBinary length = %d, want %d", len(data), 1+4*len(want))
+			}
+			if got := order.Uint32(data[1:]); got != 0x01020304 {
+				t.Errorf("first element encoded as %#x, want 0x01020304", got)
+			}
+
+			decoded, err := DecodeBinary[uint32](data[1:], order)
+			if err != nil {
+				t.Fatalf("DecodeBinary error: %v", err)
+			}
+			if fmt.Sprint(decoded.ValueSlice()) != fmt.Sprint(want) {
+				t.Errorf("round trip = %v, want %v", decoded.ValueSlice(), want)
+			}
+		})
+	}
+
+	t.Run("Float64", func(t *testing.T) {
+		fp := NewChunkPipe[float64]()
+		fp.Push([]float64{1.5, -2.25})
+		decoded, err := DecodeBinary[float64](AppendBinary(fp, nil, binary.BigEndian), binary.BigEndian)
+		if err != nil || fmt.Sprint(decoded.ValueSlice()) != "[1.5 -2.25]" {
+			t.Errorf("float round trip = %v, %v", decoded.ValueSlice(), err)
+		}
+	})
+
+	t.Run("InvalidLength", func(t *testing.T) {
+		if _, err := DecodeBinary[uint32]([]byte{1, 2, 3}, binary.LittleEndian); err != ErrBinaryLength {
+			t.Errorf("DecodeBinary error = %v, want ErrBinaryLength", err)
+		}
+	})
+}
+
+func TestChunkSliceCopy(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	cp.Push([]int{1, 2, 3})
+	cp.Push([]int{4, 5, 6, 7, 8, 9, 10, 11, 12})
+
+	copies := cp.ChunkSliceCopy()
+	views := cp.ChunkSlice()
+	cp.FillRange(0, cp.Len(), 0)
+
+	if fmt.Sprint(copies) != "[[1 2 3] [4 5 6 7 8 9 10 11 12]]" {
+		t.Errorf("retained copies changed after mutation: %v", copies)
+	}
+	if views[0][0] != 0 {
+		t.Errorf("ChunkSlice view = %v, expected it to alias pipe memory", views[0])
+	}
+
+	copies[0][0] = 99
+	if v, _ := cp.Get(0); v != 0 {
+		t.Errorf("modifying a copy changed the pipe: Get(0) = %v", v)
+	}
+
+	// 取空的區塊會被之後的推入重複使用，視圖只在下一次彈出或推入之前有效，副本則不受影響
+	owned := New[int](WithChunkSize(4))
+	owned.Push([]int{1, 2, 3, 4})
+	copies, views = owned.ChunkSliceCopy(), owned.ChunkSlice()
+	owned.PopFrontN(4)
+	owned.Push([]int{5, 6, 7, 8})
+	if !slices.Equal(copies[0], []int{1, 2, 3, 4}) {
+		t.Errorf("retained copy changed after pop and push: %v", copies[0])
+	}
+	if !slices.Equal(views[0], []int{5, 6, 7, 8}) {
+		t.Errorf("ChunkSlice view = %v, expected the emptied chunk to be reused", views[0])
+	}
+	if len(NewChunkPipe[int]().ChunkSliceCopy()) != 0 {
+		t.Error("ChunkSliceCopy on empty pipe should return no chunks")
+	}
+}
+
+// assertInvariants 在測試中驗證管道的內部不變量
+func assertInvariants(t *testing.T, cp *ChunkPipe[int], step int) {
+	t.Helper()
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+	if err := cp.checkInvariants(); err != nil {
+		t.Fatalf("step %d: invariant violated: %v", step, err)
+	}
+}
+
+func TestInvariantsRandomOps(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cp := NewChunkPipe[int]()
+	next := 0
+	for step := 0; step < 5000; step++ {
+		switch rng.Intn(8) {
+		case 0, 1:
+			data := make([]int, rng.Intn(20))
+			for i := range data {
+				data[i] = next
+				next++
+			}
+			cp.Push(data)
+		case 2:
+			cp.PopFront()
+		case 3:
+			cp.PopEnd()
+		case 4:
+			cp.PopChunkFront()
+		case 5:
+			cp.PopChunkEnd()
+		case 6:
+			if n := cp.Len(); n > 0 {
+				start := rng.Intn(n)
+				cp.FillRange(start, start+rng.Intn(n-start+1), -1)
+			}
+		case 7:
+			if rng.Intn(10) == 0 {
+				cp.Repartition(1 + rng.Intn(32))
+			}
+		}
+		assertInvariants(t, cp, step)
+	}
+}
+
+// FuzzReferenceModel 對 ChunkPipe 與一般切片套用相同的操作序列，
+// 每一步都比對兩者內容是否一致
+func FuzzReferenceModel(f *testing.F) {
+	f.Add([]byte{0, 3, 0, 12, 2, 3, 4, 5, 1, 6, 7, 2})
+	f.Add([]byte{0, 9, 0, 1, 0, 2, 5, 4, 3, 3, 3, 7, 8, 0, 20, 1, 1})
+	f.Add([]byte{0, 30, 6, 5, 3, 1, 1, 7, 4, 2, 2, 5, 0, 2, 3})
+
+	f.Fuzz(func(t *testing.T, ops []byte) {
+		cp := NewChunkPipe[int]()
+		var ref []int
+		next := 0
+
+		for step := 0; len(ops) > 0; step++ {
+			op := ops[0] % 20
+			arg := 0
+			if len(ops) > 1 {
+				arg = int(ops[1])
+			}
+			ops = ops[1:]
+
+			switch op {
+			case 0:
+				data := make([]int, arg%24)
+				for i := range data {
+					data[i] = next
+					next++
+				}
+				cp.Push(data)
+				ref = append(ref, data...)
+				ops = ops[min(1, len(ops)):]
+			case 1:
+				v, ok := cp.PopFront()
+				if ok != (len(ref) > 0) || ok && v != ref[0] {
+					t.Fatalf("step %d: PopFront = %v, %v", step, v, ok)
+				}
+				if ok {
+					ref = ref[1:]
+				}
+			case 2:
+				v, ok := cp.PopEnd()
+				if ok != (len(ref) > 0) || ok && v != ref[len(ref)-1] {
+					t.Fatalf("step %d: PopEnd = %v, %v", step, v, ok)
+				}
+				if ok {
+					ref = ref[:len(ref)-1]
+				}
+			case 3:
+				chunk, ok := cp.PopChunkFront()
+				if ok != (len(ref) > 0) || fmt.Sprint(chunk) != fmt.Sprint(ref[:len(chunk)]) {
+					t.Fatalf("step %d: PopChunkFront = %v, %v", step, chunk, ok)
+				}
+				ref = ref[len(chunk):]
+			case 4:
+				chunk, ok := cp.PopChunkEnd()
+				if ok != (len(ref) > 0) || fmt.Sprint(chunk) != fmt.Sprint(ref[len(ref)-len(chunk):]) {
+					t.Fatalf("step %d: PopChunkEnd = %v, %v", step, chunk, ok)
+				}
+				ref = ref[:len(ref)-len(chunk)]
+			case 5:
+				v, ok := cp.Get(arg)
+				if ok != (arg < len(ref)) || ok && v != ref[arg] {
+					t.Fatalf("step %d: Get(%d) = %v, %v", step, arg, v, ok)
+				}
+				ops = ops[min(1, len(ops)):]
+			case 6:
+				if len(ref) > 0 {
+					start := arg % len(ref)
+					end := start + (arg % 3)
+					end = min(end, len(ref))
+					cp.FillRange(start, end, -arg)
+					for i := start; i < end; i++ {
+						ref[i] = -arg
+					}
+				}
+				ops = ops[min(1, len(ops)):]
+			case 7:
+				cp.Repartition(1 + arg%16)
+				ops = ops[min(1, len(ops)):]
+			case 8:
+				start := min(arg%4, len(ref))
+				end := min(start+arg%3, len(ref))
+				data := make([]int, arg%5)
+				for i := range data {
+					data[i] = next
+					next++
+				}
+				if !cp.Replace(start, end, data) {
+					t.Fatalf("step %d: Replace(%d, %d) returned false", step, start, end)
+				}
+				ref = slices.Replace(ref, start, end, data...)
+				ops = ops[min(1, len(ops)):]
+			case 9:
+				data := make([]int, arg%12)
+				for i := range data {
+					data[i] = next
+					next++
+				}
+				cp.PushFront(data)
+				ref = append(slices.Clone(data), ref...)
+				ops = ops[min(1, len(ops)):]
+			case 10:
+				n := arg % 20
+				got, ok := cp.PopFrontN(n)
+				if ok != (n <= len(ref)) || ok && !slices.Equal(got, ref[:n]) {
+					t.Fatalf("step %d: PopFrontN(%d) = %v, %v", step, n, got, ok)
+				}
+				if ok {
+					ref = ref[n:]
+				}
+				ops = ops[min(1, len(ops)):]
+			case 11:
+				n := arg % 20
+				got, ok := cp.PopEndN(n)
+				if ok != (n <= len(ref)) || ok && !slices.Equal(got, ref[len(ref)-n:]) {
+					t.Fatalf("step %d: PopEndN(%d) = %v, %v", step, n, got, ok)
+				}
+				if ok {
+					ref = ref[:len(ref)-n]
+				}
+				ops = ops[min(1, len(ops)):]
+			case 12:
+				index := arg % (len(ref) + 2)
+				data := make([]int, arg%10)
+				for i := range data {
+					data[i] = next
+					next++
+				}
+				ok := cp.Insert(index, data)
+				if ok != (index <= len(ref)) {
+					t.Fatalf("step %d: Insert(%d) = %v", step, index, ok)
+				}
+				if ok {
+					ref = slices.Insert(ref, index, data...)
+				}
+				ops = ops[min(1, len(ops)):]
+			case 13:
+				index := arg % (len(ref) + 1)
+				v, ok := cp.RemoveAt(index)
+				if ok != (index < len(ref)) || ok && v != ref[index] {
+					t.Fatalf("step %d: RemoveAt(%d) = %v, %v", step, index, v, ok)
+				}
+				if ok {
+					ref = slices.Delete(ref, index, index+1)
+				}
+				ops = ops[min(1, len(ops)):]
+			case 14:
+				index := arg % (len(ref) + 1)
+				if ok := cp.Set(index, next); ok != (index < len(ref)) {
+					t.Fatalf("step %d: Set(%d) = %v", step, index, ok)
+				} else if ok {
+					ref[index] = next
+				}
+				next++
+				ops = ops[min(1, len(ops)):]
+			case 15:
+				i, j := arg%(len(ref)+1), 0
+				if len(ops) > 1 {
+					j = int(ops[1]) % (len(ref) + 1)
+				}
+				ok := cp.Swap(i, j)
+				if ok != (i < len(ref) && j < len(ref)) {
+					t.Fatalf("step %d: Swap(%d, %d) = %v", step, i, j, ok)
+				}
+				if ok {
+					ref[i], ref[j] = ref[j], ref[i]
+				}
+				ops = ops[min(2, len(ops)):]
+			case 16:
+				cp.Reverse()
+				slices.Reverse(ref)
+			case 17:
+				Sort(cp)
+				slices.Sort(ref)
+			case 18:
+				m := arg%3 + 2
+				pred := func(v int) bool { return v%m == 0 }
+				yes, no := cp.Partition(pred)
+				yes.Append(no)
+				cp = yes
+				ref = append(slices.DeleteFunc(slices.Clone(ref), func(v int) bool { return !pred(v) }),
+					slices.DeleteFunc(ref, pred)...)
+				ops = ops[min(1, len(ops)):]
+			case 19:
+				m := arg%4 + 1
+				eq := func(a, b int) bool { return a/m == b/m }
+				n, want := cp.Dedup(eq), len(ref)
+				if ref = slices.CompactFunc(ref, eq); n != want-len(ref) {
+					t.Fatalf("step %d: Dedup removed %d", step, n)
+				}
+				ops = ops[min(1, len(ops)):]
+			}
+
+			if got := cp.ValueSlice(); fmt.Sprint(got) != fmt.Sprint(ref) {
+				t.Fatalf("step %d: content = %v, want %v", step, got, ref)
+			}
+			assertInvariants(t, cp, step)
+		}
+	})
+}
+
+func TestToMap(t *testing.T) {
+	if m := ToMap(NewChunkPipe[string]()); m == nil || len(m) != 0 {
+		t.Errorf("ToMap on empty pipe = %v, want empty map", m)
+	}
+
+	cp := NewChunkPipe[string]()
+	cp.Push([]string{"a", "b", "a"})
+	cp.Push([]string{"c", "a", "b", "d", "e", "f", "g", "h", "a"})
+	cp.PopEnd()
+
+	want := map[string]int{}
+	for _, v := range cp.ValueSlice() {
+		want[v]++
+	}
+	got := ToMap(cp)
+	if len(got) != len(want) {
+		t.Fatalf("ToMap = %v, want %v", got, want)
+	}
+	for k, n := range want {
+		if got[k] != n {
+			t.Errorf("count[%q] = %d, want %d", k, got[k], n)
+		}
+	}
+	if got["a"] != 3 {
+		t.Errorf("count[a] = %d, want 3", got["a"])
+	}
+}
+
+func TestSPSCChunkPipe(t *testing.T) {
+	t.Run("Sequential", func(t *testing.T) {
+		p := NewSPSC[int](4)
+		if _, ok := p.PopFront(); ok {
+			t.Error("PopFront should return false for empty pipe")
+		}
+		p.Push([]int{1, 2, 3}).Push([]int{4, 5, 6, 7, 8, 9})
+		if p.Len() != 9 {
+			t.Errorf("Len = %d, want 9", p.Len())
+		}
+		for want := 1; want <= 9; want++ {
+			if v, ok := p.PopFront(); !ok || v != want {
+				t.Fatalf("PopFront = %v, %v, want %d, true", v, ok, want)
+			}
+		}
+		if _, ok := p.PopFront(); ok || p.Len() != 0 {
+			t.Error("pipe should be empty")
+		}
+	})
+
+	t.Run("ProducerConsumer", func(t *testing.T) {
+		const total = 100000
+		p := NewSPSC[int](64)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			batch := make([]int, 7)
+			for i := 0; i < total; i += len(batch) {
+				for j := range batch {
+					batch[j] = i + j
+				}
+				p.Push(batch[:min(len(batch), total-i)])
+			}
+		}()
+
+		for want := 0; want < total; {
+			v, ok := p.PopFront()
+			if !ok {
+				continue
+			}
+			if v != want {
+				t.Fatalf("PopFront = %d, want %d", v, want)
+			}
+			want++
+		}
+		<-done
+	})
+}
+
+func TestPushAll(t *testing.T) {
+	a := []int{1, 2, 3}
+	b := []int{}
+	c := []int{4, 5, 6, 7, 8, 9, 10, 11, 12, 13}
+	d := []int{14}
+
+	separate := NewChunkPipe[int]()
+	separate.Push(a).Push(b).Push(c).Push(d)
+
+	batched := NewChunkPipe[int]()
+	var pushed []int
+	batched.OnPush(func(n int) { pushed = append(pushed, n) })
+	batched.PushAll(a, b, c, d)
+
+	if fmt.Sprint(batched.ValueSlice()) != fmt.Sprint(separate.ValueSlice()) {
+		t.Errorf("PushAll = %v, want %v", batched.ValueSlice(), separate.ValueSlice())
+	}
+	if len(batched.ChunkSlice()) != len(separate.ChunkSlice()) {
+		t.Errorf("PushAll chunks = %d, want %d", len(batched.ChunkSlice()), len(separate.ChunkSlice()))
+	}
+	if fmt.Sprint(pushed) != "[14]" {
+		t.Errorf("push hook calls = %v, want [14]", pushed)
+	}
+
+	select {
+	case <-batched.Notify():
+		t.Error("Notify created after the push should not be signalled")
+	default:
+	}
+	empty := NewChunkPipe[int]()
+	ch := empty.Notify()
+	empty.PushAll(nil, []int{})
+	empty.PushAll(nil, []int{1})
+	if len(ch) != 1 {
+		t.Errorf("pending notifications = %d, want 1", len(ch))
+	}
+}
+
+func TestWindows(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	cp.Push([]int{0, 1, 2})
+	cp.Push([]int{3})
+	cp.Push([]int{4, 5, 6, 7, 8, 9})
+
+	var got [][]int
+	for w := range cp.Windows(3, 2) {
+		got = append(got, w)
+	}
+	want := "[[0 1 2] [2 3 4] [4 5 6] [6 7 8]]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("Windows(3, 2) = %v, want %s", got, want)
+	}
+
+	// 視窗為獨立副本
+	got[0][0] = 99
+	if v, _ := cp.Get(0); v != 0 {
+		t.Errorf("modifying a window changed the pipe: Get(0) = %v", v)
+	}
+
+	count := 0
+	for range cp.Windows(2, 1) {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+	if count != 3 {
+		t.Errorf("break after 3 windows, got %d", count)
+	}
+
+	for range cp.Windows(11, 1) {
+		t.Error("Windows larger than the pipe should yield nothing")
+	}
+	for range cp.Windows(0, 1) {
+		t.Error("Windows with size 0 should yield nothing")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	newPipe := func() *ChunkPipe[int] {
+		cp := NewChunkPipe[int]()
+		cp.Push([]int{0, 1, 2})
+		cp.Push([]int{3, 4, 5, 6, 7, 8, 9, 10, 11})
+		return cp
+	}
+
+	tests := []struct {
+		name       string
+		start, end int
+		data       []int
+		want       string
+	}{
+		{"Shrink", 2, 6, []int{-1}, "[0 1 -1 6 7 8 9 10 11]"},
+		{"Grow", 1, 2, []int{-1, -2, -3}, "[0 -1 -2 -3 2 3 4 5 6 7 8 9 10 11]"},
+		{"EqualSize", 4, 7, []int{-4, -5, -6}, "[0 1 2 3 -4 -5 -6 7 8 9 10 11]"},
+		{"InsertOnly", 3, 3, []int{-1}, "[0 1 2 -1 3 4 5 6 7 8 9 10 11]"},
+		{"RemoveOnly", 0, 11, nil, "[11]"},
+		{"Append", 12, 12, []int{12}, "[0 1 2 3 4 5 6 7 8 9 10 11 12]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp := newPipe()
+			if !cp.Replace(tt.start, tt.end, tt.data) {
+				t.Fatal("Replace returned false")
+			}
+			if got := fmt.Sprint(cp.ValueSlice()); got != tt.want {
+				t.Errorf("ValueSlice = %s, want %s", got, tt.want)
+			}
+			assertInvariants(t, cp, 0)
+		})
+	}
+
+	t.Run("SplitChunkTail", func(t *testing.T) {
+		cp := NewChunkPipe[int]()
+		cp.Push([]int{0, 1, 2, 3})
+		cp.Replace(2, 4, nil)
+		// 左半部成為尾端後，原地追加不可覆寫被移除的區段之外的記憶體
+		cp.Push([]int{9})
+		if got := fmt.Sprint(cp.ValueSlice()); got != "[0 1 9]" {
+			t.Errorf("ValueSlice = %s, want [0 1 9]", got)
+		}
+		assertInvariants(t, cp, 0)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		cp := newPipe()
+		if cp.Replace(-1, 2, nil) || cp.Replace(3, 2, nil) || cp.Replace(0, 13, nil) {
+			t.Error("Replace should return false for invalid ranges")
+		}
+	})
+}
+
+func TestCopyOnPush(t *testing.T) {
+	source := func() []int {
+		return []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	}
+
+	t.Run("Copy", func(t *testing.T) {
+		for _, cp := range []*ChunkPipe[int]{NewChunkPipe[int](), New[int](WithCopyOnPush(true))} {
+			data := source()
+			cp.Push(data)
+			data[3] = -1
+			if v, _ := cp.Get(3); v != 3 {
+				t.Errorf("Get(3) = %v after mutating source, want 3", v)
+			}
+		}
+	})
+
+	t.Run("Alias", func(t *testing.T) {
+		cp := New[int](WithCopyOnPush(false))
+		data := source()
+		cp.Push(data)
+		data[3] = -1
+		if v, _ := cp.Get(3); v != -1 {
+			t.Errorf("Get(3) = %v after mutating source, want -1", v)
+		}
+
+		// 小資料在別名模式下仍會被複製
+		small := []int{1, 2}
+		cp.Push(small)
+		small[0] = -1
+		if v, _ := cp.Get(10); v != 1 {
+			t.Errorf("Get(10) = %v after mutating small source, want 1", v)
+		}
+	})
+}
+
+func TestRangeValues(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	for i := 0; i < 100; i += 10 {
+		cp.Push([]int{i, i + 1, i + 2, i + 3, i + 4, i + 5, i + 6, i + 7, i + 8, i + 9})
+	}
+
+	for _, batch := range []int{0, 1, 7, 16, 100, 1000} {
+		var got []int
+		cp.RangeValuesN(batch, func(v int) bool {
+			got = append(got, v)
+			return true
+		})
+		if fmt.Sprint(got) != fmt.Sprint(cp.ValueSlice()) {
+			t.Errorf("RangeValuesN(%d) = %v", batch, got)
+		}
+	}
+
+	count := 0
+	cp.RangeValues(func(v int) bool {
+		count++
+		return v < 20
+	})
+	if count != 21 {
+		t.Errorf("RangeValues stopped after %d elements, want 21", count)
+	}
+
+	// fn 在鎖外執行，可以修改管道
+	var got []int
+	cp.RangeValuesN(4, func(v int) bool {
+		got = append(got, v)
+		if v == 1 {
+			for i := 0; i < 10; i++ {
+				cp.PopFront()
+			}
+		}
+		return v < 15
+	})
+	if want := "[0 1 2 3 10 11 12 13 14 15]"; fmt.Sprint(got) != want {
+		t.Errorf("RangeValuesN with mutation = %v, want %s", got, want)
+	}
+}
+
+func TestGetAfterPops(t *testing.T) {
+	for _, tree := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Tree-%v", tree), func(t *testing.T) {
+			cp := New[int](WithTree(tree), WithCopyOnPush(false))
+			var ref []int
+			for i := 0; i < 10; i++ {
+				chunk := make([]int, 5+i)
+				for j := range chunk {
+					chunk[j] = len(ref) + j
+				}
+				cp.Push(chunk)
+				ref = append(ref, chunk...)
+			}
+
+			for i := 0; i < 7; i++ {
+				cp.PopFront()
+			}
+			for i := 0; i < 16; i++ {
+				cp.PopEnd()
+			}
+			ref = ref[7 : len(ref)-16]
+
+			n := cp.Len()
+			if n != len(ref) {
+				t.Fatalf("size = %d, want %d", n, len(ref))
+			}
+			for _, i := range []int{0, n / 2, n - 1} {
+				if v, ok := cp.Get(i); !ok || v != ref[i] {
+					t.Errorf("Get(%d) = %v, %v, want %d, true", i, v, ok, ref[i])
+				}
+			}
+			for i := range ref {
+				if v, _ := cp.Get(i); v != ref[i] {
+					t.Fatalf("Get(%d) = %v, want %d", i, v, ref[i])
+				}
+			}
+			if _, ok := cp.Get(n); ok {
+				t.Error("Get past the end should return false")
+			}
+		})
+	}
+}
+
+func TestChunkLens(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	if cp.NumChunks() != 0 || len(cp.ChunkLens()) != 0 {
+		t.Error("empty pipe should have no chunks")
+	}
+
+	cp.Push(make([]int, 10))
+	cp.Push(make([]int, 20))
+	cp.Push([]int{1, 2, 3})
+	cp.Push(make([]int, 15))
+	cp.PopFront()
+	cp.PopFront()
+	cp.PopEnd()
+
+	if got := fmt.Sprint(cp.ChunkLens()); got != "[8 20 3 14]" {
+		t.Errorf("ChunkLens = %s, want [8 20 3 14]", got)
+	}
+	chunks := cp.ChunkSlice()
+	if cp.NumChunks() != len(chunks) {
+		t.Errorf("NumChunks = %d, want %d", cp.NumChunks(), len(chunks))
+	}
+	for i, n := range cp.ChunkLens() {
+		if n != len(chunks[i]) {
+			t.Errorf("chunk %d length = %d, want %d", i, n, len(chunks[i]))
+		}
+	}
+}
+
+func TestPopChunkFrontPooled(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	cp.Push([]int{1, 2, 3})
+
+	chunk, release, ok := cp.PopChunkFrontPooled()
+	if !ok || fmt.Sprint(chunk) != "[1 2 3]" {
+		t.Fatalf("PopChunkFrontPooled = %v, %v", chunk, ok)
+	}
+	prev := &chunk[0]
+	release()
+	release() // 重複呼叫無副作用
+
+	// 競態偵測模式下 sync.Pool 會隨機丟棄歸還的物件，因此允許重試
+	reused := false
+	for i := 0; i < 20 && !reused; i++ {
+		cp.Push([]int{i, i})
+		chunk, release, ok = cp.PopChunkFrontPooled()
+		if !ok || chunk[0] != i || len(chunk) != 2 {
+			t.Fatalf("PopChunkFrontPooled = %v, %v", chunk, ok)
+		}
+		reused = &chunk[0] == prev
+		prev = &chunk[0]
+		release()
+	}
+	if !reused {
+		t.Error("released backing array was not reused")
+	}
+
+	// 不呼叫歸還函式也不影響後續操作
+	cp.Push([]int{6})
+	if chunk, _, ok := cp.PopChunkFrontPooled(); !ok || chunk[0] != 6 {
+		t.Errorf("PopChunkFrontPooled = %v, %v, want [6], true", chunk, ok)
+	}
+	if _, release, ok := cp.PopChunkFrontPooled(); ok {
+		t.Error("PopChunkFrontPooled on empty pipe should return false")
+	} else {
+		release()
+	}
+
+	// 借用的區塊不會被回收
+	alias := New[int](WithCopyOnPush(false))
+	data := make([]int, 20)
+	alias.Push(data)
+	_, release, _ = alias.PopChunkFrontPooled()
+	release()
+	alias.Push([]int{7})
+	if chunk, _, _ := alias.PopChunkFrontPooled(); &chunk[0] == &data[0] {
+		t.Error("borrowed caller memory was reused by the pipe")
+	}
+}
+
+// shortReader 每次最多只返回 n 個位元組，最後返回 err
+type shortReader struct {
+	data []byte
+	n    int
+	err  error
+}
+
+func (r *shortReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, r.err
+	}
+	n := copy(p[:min(len(p), r.n)], r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestAppendReader(t *testing.T) {
+	src := make([]byte, 1000)
+	for i := range src {
+		src[i] = byte(i * 7)
+	}
+
+	t.Run("MultiBlock", func(t *testing.T) {
+		cp := NewChunkPipe[byte]()
+		n, err := AppendReader(cp, &shortReader{data: src, n: 33, err: io.EOF}, 128)
+		if err != nil || n != len(src) {
+			t.Fatalf("AppendReader = %d, %v, want %d, nil", n, err, len(src))
+		}
+		if !bytes.Equal(cp.ValueSlice(), src) {
+			t.Error("pipe content differs from source")
+		}
+		if got := fmt.Sprint(cp.ChunkLens()); got != "[128 128 128 128 128 128 128 104]" {
+			t.Errorf("ChunkLens = %s", got)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		cp := NewChunkPipe[byte]()
+		boom := errors.New("boom")
+		n, err := AppendReader(cp, &shortReader{data: src[:300], n: 100, err: boom}, 256)
+		if err != boom || n != 300 {
+			t.Fatalf("AppendReader = %d, %v, want 300, boom", n, err)
+		}
+		if !bytes.Equal(cp.ValueSlice(), src[:300]) {
+			t.Error("data read before the error should be kept")
+		}
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		cp := NewChunkPipe[byte]()
+		if n, err := AppendReader(cp, bytes.NewReader(nil), 0); n != 0 || err != nil || cp.NumChunks() != 0 {
+			t.Errorf("AppendReader on empty reader = %d, %v, chunks = %d", n, err, cp.NumChunks())
+		}
+	})
+}
+
+type record struct {
+	id      int
+	name    string
+	touched int // 比較時忽略
+}
+
+func TestEqualFunc(t *testing.T) {
+	eq := func(x, y record) bool { return x.id == y.id && x.name == y.name }
+
+	a := New[record](WithCopyOnPush(false))
+	a.Push([]record{{1, "a", 0}, {2, "b", 0}})
+	a.Push([]record{{3, "c", 0}, {4, "d", 0}, {5, "e", 0}, {6, "f", 0}, {7, "g", 0}, {8, "h", 0}, {9, "i", 0}})
+
+	b := New[record](WithCopyOnPush(false))
+	b.Push([]record{{0, "x", 0}, {1, "a", 5}, {2, "b", 5}, {3, "c", 5}, {4, "d", 0}, {5, "e", 0}, {6, "f", 0}, {7, "g", 0}, {8, "h", 0}})
+	b.Push([]record{{9, "i", 1}})
+	b.PopFront()
+
+	if !EqualFunc(a, b, eq) || !EqualFunc(b, a, eq) {
+		t.Error("pipes with equal content in different chunk layouts should be equal")
+	}
+	if !EqualFunc(a, a, eq) {
+		t.Error("a pipe should equal itself")
+	}
+
+	b.PopEnd()
+	if EqualFunc(a, b, eq) {
+		t.Error("pipes with different lengths should not be equal")
+	}
+	b.Push([]record{{10, "i", 0}})
+	if EqualFunc(a, b, eq) {
+		t.Error("pipes with different elements should not be equal")
+	}
+	if !EqualFunc(NewChunkPipe[record](), NewChunkPipe[record](), eq) {
+		t.Error("empty pipes should be equal")
+	}
+}
+
+func TestZeroSizedElements(t *testing.T) {
+	for _, copyOnPush := range []bool{true, false} {
+		t.Run(fmt.Sprintf("Copy-%v", copyOnPush), func(t *testing.T) {
+			cp := New[struct{}](WithCopyOnPush(copyOnPush))
+			for i := 0; i < 100; i++ {
+				cp.Push(make([]struct{}, i%13))
+			}
+			want := 0
+			for i := 0; i < 100; i++ {
+				want += i % 13
+			}
+			if cp.Len() != want {
+				t.Fatalf("size = %d, want %d", cp.Len(), want)
+			}
+
+			popped := 0
+			for i := 0; i < 100; i++ {
+				if _, ok := cp.PopFront(); ok {
+					popped++
+				}
+				if _, ok := cp.PopEnd(); ok {
+					popped++
+				}
+			}
+			if popped != 200 || cp.Len() != want-200 {
+				t.Errorf("popped %d, size = %d, want 200, %d", popped, cp.Len(), want-200)
+			}
+			if _, ok := cp.Get(want - 201); !ok {
+				t.Error("Get of last element should succeed")
+			}
+
+			for {
+				if _, ok := cp.PopFront(); !ok {
+					break
+				}
+				popped++
+			}
+			if popped != want || cp.Len() != 0 {
+				t.Errorf("popped %d in total, size = %d, want %d, 0", popped, cp.Len(), want)
+			}
+			if err := cp.CheckInvariants(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestTwoPipeLockOrdering(t *testing.T) {
+	a := NewChunkPipe[int]()
+	b := NewChunkPipe[int]()
+	a.Push([]int{1, 2, 3})
+	b.Push([]int{1, 2, 3})
+	eq := func(x, y int) bool { return x == y }
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				if g%2 == 0 {
+					EqualFunc(a, b, eq)
+				} else {
+					EqualFunc(b, a, eq)
+				}
+			}
+		}(g)
+	}
+	// 寫入者讓讀鎖與寫鎖持續交錯
+	for _, cp := range []*ChunkPipe[int]{a, b} {
+		wg.Add(1)
+		go func(cp *ChunkPipe[int]) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				cp.Push([]int{i})
+				cp.PopEnd()
+			}
+		}(cp)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatal("reciprocal two-pipe operations deadlocked")
+	}
+}
+
+func TestTrimHeadChunk(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	data := make([]int, 10000)
+	for i := range data {
+		data[i] = i
+	}
+	cp.Push(data)
+	cp.Push([]int{-1})
+
+	maxWasted := 0
+	for i := 0; i < 9000; i++ {
+		if v, ok := cp.PopFront(); !ok || v != i {
+			t.Fatalf("PopFront = %v, %v, want %d, true", v, ok, i)
+		}
+		head := cp.list[0]
+		maxWasted = max(maxWasted, head.dead)
+		if head.dead > trimMinDead && head.dead > trimDeadRatio*len(head.val) {
+			t.Fatalf("after %d pops: %d dead elements for %d live ones", i+1, head.dead, len(head.val))
+		}
+	}
+	if head := cp.list[0]; cap(head.val) >= len(data) {
+		t.Errorf("head chunk still holds the original %d-element array", cap(head.val))
+	}
+	if maxWasted >= len(data) {
+		t.Errorf("wasted prefix grew to %d", maxWasted)
+	}
+
+	want := append(data[9000:], -1)
+	if fmt.Sprint(cp.ValueSlice()) != fmt.Sprint(want) {
+		t.Error("content changed after trimming the head chunk")
+	}
+	assertInvariants(t, cp, 0)
+}
+
+func TestFlatten(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	for i := 0; i < 20; i++ {
+		cp.Push([]int{i, i, i, i, i, i, i, i, i, i})
+	}
+	cp.PopFront()
+	cp.PopEnd()
+	want := cp.ValueSlice()
+
+	cp.Flatten()
+	if cp.NumChunks() != 1 {
+		t.Fatalf("NumChunks = %d, want 1", cp.NumChunks())
+	}
+	view, pos, ok := cp.ChunkAt(0)
+	if !ok || pos != 0 || fmt.Sprint(view) != fmt.Sprint(want) {
+		t.Errorf("single chunk view differs from ValueSlice")
+	}
+	assertInvariants(t, cp, 0)
+
+	empty := NewChunkPipe[int]()
+	empty.Flatten()
+	if empty.NumChunks() != 0 {
+		t.Error("Flatten on empty pipe should not create chunks")
+	}
+}
+
+func TestStatsLoadFactor(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	if lf := cp.LoadFactor(); lf != 1 {
+		t.Errorf("LoadFactor of empty pipe = %v, want 1", lf)
+	}
+
+	for i := 0; i < 4; i++ {
+		cp.Push(make([]int, 100))
+	}
+	s := cp.Stats()
+	if s.Chunks != 4 || s.Elements != 400 || s.AllocatedElements != 400 {
+		t.Errorf("Stats = %+v, want 4 chunks, 400 elements, 400 allocated", s)
+	}
+	if lf := cp.LoadFactor(); lf != 1 {
+		t.Errorf("LoadFactor = %v, want 1", lf)
+	}
+
+	for i := 0; i < 60; i++ {
+		cp.PopFront()
+		cp.PopEnd()
+	}
+	s = cp.Stats()
+	if s.Elements != 280 || s.AllocatedElements != 400 {
+		t.Errorf("Stats after pops = %+v, want 280 elements, 400 allocated", s)
+	}
+	if lf := cp.LoadFactor(); lf > 0.71 {
+		t.Errorf("LoadFactor after pops = %v, want about 0.7", lf)
+	}
+
+	cp.Flatten()
+	if lf := cp.LoadFactor(); lf < 0.99 {
+		t.Errorf("LoadFactor after Flatten = %v, want about 1", lf)
+	}
+}
+
+func TestStatsCounters(t *testing.T) {
+	cp := New[int64](WithInitialCapacity(32), WithChunkSize(32))
+	cp.PushCopy(make([]int64, 20)) // 使用預先分配的區塊
+	cp.PushCopy(make([]int64, 20)) // 剩餘容量不足，配置新區塊
+	cp.PushCopy(make([]int64, 10)) // 寫入第二個區塊的剩餘容量
+	for i := 0; i < 5; i++ {
+		cp.PopFront()
+	}
+
+	s := cp.Stats()
+	if s.Pushed != 50 || s.Popped != 5 || s.ChunksReused != 1 || s.ChunksAllocated != 1 {
+		t.Errorf("counters = %+v, want 50 pushed, 5 popped, 1 reused, 1 allocated", s)
+	}
+	if s.LiveBytes != 45*8 || s.AllocatedBytes != 64*8 || s.DeadElements != 5 || s.DeadBytes != 5*8 {
+		t.Errorf("byte stats = %+v", s)
+	}
+	if want := (15.0/32 + 30.0/32) / 2; math.Abs(s.AvgChunkFill-want) > 1e-9 {
+		t.Errorf("AvgChunkFill = %v, want %v", s.AvgChunkFill, want)
+	}
+
+	// Clear 歸還的區塊放回池中，下一次推入可能重複使用（池的內容不保證保留）；
+	// 移動到其他管道也計入推入與移除
+	cp.Clear()
+	cp.PushCopy(make([]int64, 20))
+	other := New[int64]()
+	other.Append(cp)
+	if s := cp.Stats(); s.Popped != 70 || s.ChunksReused+s.ChunksAllocated != 3 || s.Chunks != 0 || s.AvgChunkFill != 0 {
+		t.Errorf("Stats after Clear and Append = %+v", s)
+	}
+	if s := other.Stats(); s.Pushed != 20 || s.Popped != 0 {
+		t.Errorf("destination Stats after Append = %+v", s)
+	}
+}
+
+func TestIndexAfterSplits(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	cp := New[int](WithCopyOnPush(false))
+	var ref []int
+	for i := 0; i < 20; i++ {
+		chunk := make([]int, 10)
+		for j := range chunk {
+			chunk[j] = len(ref) + j
+		}
+		cp.Push(chunk)
+		ref = append(ref, chunk...)
+	}
+
+	next := -1
+	for step := 0; step < 300; step++ {
+		start := rng.Intn(len(ref) + 1)
+		if rng.Intn(2) == 0 {
+			// 插入：在區塊中間切開並放入新區塊
+			data := make([]int, 1+rng.Intn(5))
+			for i := range data {
+				data[i] = next
+				next--
+			}
+			cp.Replace(start, start, data)
+			ref = slices.Insert(ref, start, data...)
+		} else if start < len(ref) {
+			// 刪除：可能跨越多個區塊
+			end := min(len(ref), start+1+rng.Intn(8))
+			cp.Replace(start, end, nil)
+			ref = slices.Delete(ref, start, end)
+		}
+		assertInvariants(t, cp, step)
+	}
+
+	linear := func(index int) (int, bool) {
+		cp.mu.Lock()
+		defer cp.mu.Unlock()
+		cp.noTree = true
+		defer func() { cp.noTree = false }()
+		i, pos, ok := cp.locate(index)
+		if !ok {
+			return 0, false
+		}
+		return cp.list[i].val[pos], true
+	}
+	for i := range ref {
+		got, ok := cp.Get(i)
+		lin, _ := linear(i)
+		if !ok || got != ref[i] || lin != ref[i] {
+			t.Fatalf("Get(%d) = %v, linear = %v, want %d", i, got, lin, ref[i])
+		}
+	}
+	if _, ok := cp.Get(len(ref)); ok {
+		t.Error("Get past the end should return false")
+	}
+}
+
+func TestCloneFunc(t *testing.T) {
+	type node struct {
+		value int
+	}
+
+	src := New[*node](WithChunkSize(4))
+	src.Push([]*node{{1}, {2}, {3}})
+	src.Push([]*node{{4}})
+	src.PopFront()
+
+	clone := CloneFunc(src, func(n *node) *node {
+		cp := *n
+		return &cp
+	})
+	if clone.Len() != 3 || clone.chunkSize != 4 {
+		t.Fatalf("clone size = %d, chunkSize = %d, want 3, 4", clone.Len(), clone.chunkSize)
+	}
+
+	c, _ := clone.Get(0)
+	c.value = 100
+	clone.PopEnd()
+	clone.Push([]*node{{5}})
+
+	if s, _ := src.Get(0); s.value != 2 {
+		t.Errorf("source element changed to %d via the clone", s.value)
+	}
+	if s, _ := src.Get(2); src.Len() != 3 || s.value != 4 {
+		t.Errorf("source changed after mutating the clone")
+	}
+	if _, ok := src.Get(3); ok {
+		t.Error("source should not see elements pushed to the clone")
+	}
+	assertInvariants(t, CloneFunc(NewChunkPipe[int](), func(v int) int { return v }), 0)
+}
+
+func TestRangeValuesChunkBoundaries(t *testing.T) {
+	for _, size := range []int{17, 31} {
+		t.Run(fmt.Sprintf("Chunk-%d", size), func(t *testing.T) {
+			cp := New[int](WithCopyOnPush(false))
+			var want []int
+			for i := 0; i < 5; i++ {
+				chunk := make([]int, size)
+				for j := range chunk {
+					chunk[j] = len(want) + j
+				}
+				cp.Push(chunk)
+				want = append(want, chunk...)
+			}
+			for _, batch := range []int{1, 16, 32} {
+				var got []int
+				cp.RangeValuesN(batch, func(v int) bool {
+					got = append(got, v)
+					return true
+				})
+				if fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("RangeValuesN(%d) = %v, want %v", batch, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestRangeValuesConcurrentMutation(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	next := 0
+	for ; next < 1000; next++ {
+		cp.Push([]int{next})
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 2000; i++ {
+			chunk := make([]int, 1+i%31)
+			for j := range chunk {
+				chunk[j] = next
+				next++
+			}
+			cp.Push(chunk)
+			cp.PopFront()
+			if i%7 == 0 {
+				cp.PopChunkFront()
+			}
+		}
+	}()
+
+	for r := 0; r < 20; r++ {
+		prev := -1
+		cp.RangeValues(func(v int) bool {
+			// 只從頭部彈出、尾端推入遞增的值，走訪結果必須嚴格遞增
+			if v <= prev {
+				t.Errorf("RangeValues returned %d after %d", v, prev)
+				return false
+			}
+			prev = v
+			return true
+		})
+	}
+	wg.Wait()
+}
+
+func TestPopReleasesReferences(t *testing.T) {
+	type payload struct{ buf [1 << 10]byte }
+
+	waitFinalized := func(t *testing.T, done <-chan struct{}) {
+		t.Helper()
+		for i := 0; i < 50; i++ {
+			runtime.GC()
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+		t.Fatal("popped element is still reachable from the pipe")
+	}
+
+	for _, tc := range []struct {
+		name string
+		pop  func(cp *ChunkPipe[*payload]) (*payload, bool)
+		at   int
+	}{
+		{"PopFront", (*ChunkPipe[*payload]).PopFront, 0},
+		{"PopEnd", (*ChunkPipe[*payload]).PopEnd, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cp := NewChunkPipe[*payload]()
+			done := make(chan struct{})
+			func() {
+				ps := []*payload{new(payload), new(payload), new(payload)}
+				runtime.SetFinalizer(ps[tc.at], func(*payload) { close(done) })
+				cp.Push(ps)
+				if p, ok := tc.pop(cp); !ok || p != ps[tc.at] {
+					t.Fatalf("%s returned %p, want %p", tc.name, p, ps[tc.at])
+				}
+			}()
+			// 區塊仍留在管道中，被移出的 slot 必須已清為零值
+			waitFinalized(t, done)
+			if cp.Len() != 2 {
+				t.Fatalf("expected 2 elements left, got %d", cp.Len())
+			}
+			runtime.KeepAlive(cp)
+		})
+	}
+}
+
+func TestHeadConsistent(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	if _, n, ok := cp.Head(); ok || n != 0 {
+		t.Fatalf("Head on empty pipe returned (%d, %v)", n, ok)
+	}
+
+	// 維持「頭部元素 == 長度-1」：每次修改都是單一的加鎖操作
+	for i := 0; i < 100; i++ {
+		cp.Replace(0, 0, []int{i})
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		n := 100
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if n > 1 && rand.Intn(2) == 0 {
+				cp.PopFront()
+				n--
+			} else {
+				cp.Replace(0, 0, []int{n})
+				n++
+			}
+		}
+	}()
+
+	for i := 0; i < 10000; i++ {
+		v, n, ok := cp.Head()
+		if !ok || v != n-1 {
+			t.Errorf("Head returned value %d with length %d", v, n)
+			break
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestToggleIndex(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false), WithTree(false))
+	for i := 0; i < 50; i++ {
+		chunk := make([]int, 1+i%13)
+		for j := range chunk {
+			chunk[j] = i
+		}
+		cp.Push(chunk)
+	}
+	for i := 0; i < 20; i++ {
+		cp.PopFront()
+	}
+	want := cp.ValueSlice()
+
+	check := func(name string) {
+		t.Helper()
+		for i, w := range want {
+			if v, ok := cp.Get(i); !ok || v != w {
+				t.Fatalf("%s: Get(%d) = %d, %v, want %d, true", name, i, v, ok, w)
+			}
+		}
+		if _, ok := cp.Get(len(want)); ok {
+			t.Fatalf("%s: Get out of range should fail", name)
+		}
+		if n := testing.AllocsPerRun(10, func() { cp.Get(len(want) / 2) }); n != 0 {
+			t.Errorf("%s: Get allocated %v times", name, n)
+		}
+	}
+	check("disabled")
+	cp.EnableIndex()
+	check("enabled")
+	cp.DisableIndex()
+	check("disabled again")
+}
+
+func TestGetMany(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	next := 0
+	for i := 0; i < 20; i++ {
+		chunk := make([]int, 1+i%7)
+		for j := range chunk {
+			chunk[j] = next
+			next++
+		}
+		cp.Push(chunk)
+	}
+	for i := 0; i < 5; i++ {
+		cp.PopFront()
+	}
+	n := cp.Len()
+
+	indices := []int{n - 1, 3, -1, 0, n, 3, 40, 1000, 17, math.MinInt, math.MaxInt, n - 2}
+	vals, found := cp.GetMany(indices)
+	if len(vals) != len(indices) || len(found) != len(indices) {
+		t.Fatalf("GetMany returned %d values and %d flags for %d indices",
+			len(vals), len(found), len(indices))
+	}
+	for i, idx := range indices {
+		v, ok := cp.Get(idx)
+		if found[i] != ok || vals[i] != v {
+			t.Errorf("GetMany index %d = %d, %v, want %d, %v", idx, vals[i], found[i], v, ok)
+		}
+	}
+
+	small := NewChunkPipe[int]()
+	small.Push([]int{0, 1, 2, 3, 4, 5})
+	small.PopFront()
+	indices = []int{math.MinInt, 3, math.MaxInt, 1, 0, -1, 4}
+	vals, found = small.GetMany(indices)
+	for i, idx := range indices {
+		v, ok := small.Get(idx)
+		if found[i] != ok || vals[i] != v {
+			t.Errorf("GetMany index %d = %d, %v, want %d, %v", idx, vals[i], found[i], v, ok)
+		}
+	}
+
+	if vals, found := NewChunkPipe[int]().GetMany([]int{0, 1}); found[0] || found[1] || vals[0] != 0 {
+		t.Error("GetMany on empty pipe should find nothing")
+	}
+}
+
+func TestNotifyWithConcurrentDrain(t *testing.T) {
+	const total = 20000
+	cp := NewChunkPipe[int]()
+	ch := cp.Notify()
+	done := make(chan struct{})
+
+	var mu sync.Mutex
+	seen := make([]bool, total)
+	delivered := 0
+	deliver := func(vs ...int) {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, v := range vs {
+			if seen[v] {
+				t.Errorf("value %d delivered twice", v)
+			}
+			seen[v] = true
+			delivered++
+		}
+	}
+
+	var wg sync.WaitGroup
+	for c := 0; c < 3; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				if v, ok := cp.PopFront(); ok {
+					deliver(v)
+					continue
+				}
+				select {
+				case <-ch:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < total; {
+			chunk := make([]int, min(1+rand.Intn(8), total-i))
+			for j := range chunk {
+				chunk[j] = i
+				i++
+			}
+			cp.Push(chunk)
+			if rand.Intn(50) == 0 {
+				for {
+					vs, ok := cp.PopChunkFront()
+					if !ok {
+						break
+					}
+					deliver(vs...)
+				}
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		mu.Lock()
+		n := delivered
+		mu.Unlock()
+		if n == total {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("only %d of %d elements delivered; consumers are stuck", n, total)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	wg.Wait()
+}
+
+func TestByteChunkPipe(t *testing.T) {
+	b := NewByteChunkPipe(WithChunkSize(4))
+	b.WriteString("hello")
+	b.WriteByte(' ')
+	if n, err := b.Printf("%d-%s", 7, "x"); err != nil || n != len(fmt.Sprintf("%d-%s", 7, "x")) {
+		t.Fatalf("Printf = %d, %v", n, err)
+	}
+	b.Write([]byte(", world"))
+
+	want := "hello " + fmt.Sprintf("%d-%s", 7, "x") + ", world"
+	if got := string(b.Bytes()); got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+	if b.NumChunks() < 2 {
+		t.Errorf("expected the writes to span several chunks, got %d", b.NumChunks())
+	}
+	if err := b.checkInvariants(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 即使關閉複製，寫入的資料也不能引用呼叫者的緩衝區
+	a := NewByteChunkPipe(WithCopyOnPush(false))
+	buf := bytes.Repeat([]byte{'a'}, 64)
+	a.Write(buf)
+	buf[0] = 'b'
+	if got, _ := a.Get(0); got != 'a' {
+		t.Error("Write should copy the caller's buffer")
+	}
+}
+
+func TestRemove(t *testing.T) {
+	for _, alias := range []bool{false, true} {
+		t.Run(fmt.Sprintf("alias=%v", alias), func(t *testing.T) {
+			cp := New[int](WithCopyOnPush(!alias), WithChunkSize(4))
+			popped := 0
+			cp.OnPop(func(n int) { popped += n })
+			for _, chunk := range [][]int{
+				{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+				{7, 11, 7, 12, 13, 14, 15, 16, 17, 7},
+				{18, 19, 20, 21, 22, 23, 24, 25, 26, 27},
+			} {
+				cp.Push(slices.Clone(chunk))
+			}
+			cp.PopFront()
+
+			if !Remove(cp, 12) {
+				t.Fatal("Remove(12) should find the value in the middle chunk")
+			}
+			if Remove(cp, 100) {
+				t.Error("Remove of a missing value should return false")
+			}
+			if !Remove(cp, 7) {
+				t.Fatal("Remove(7) should succeed")
+			}
+			want := []int{2, 3, 4, 5, 6, 8, 9, 10, 7, 11, 7, 13, 14, 15, 16, 17, 7,
+				18, 19, 20, 21, 22, 23, 24, 25, 26, 27}
+			if got := cp.ValueSlice(); !slices.Equal(got, want) {
+				t.Fatalf("after Remove got %v, want %v", got, want)
+			}
+			assertInvariants(t, cp, 1)
+
+			if n := RemoveAll(cp, 7); n != 3 {
+				t.Errorf("RemoveAll(7) = %d, want 3", n)
+			}
+			want = slices.DeleteFunc(want, func(v int) bool { return v == 7 })
+			if got := cp.ValueSlice(); !slices.Equal(got, want) {
+				t.Fatalf("after RemoveAll got %v, want %v", got, want)
+			}
+			assertInvariants(t, cp, 2)
+			for i, w := range want {
+				if v, ok := cp.Get(i); !ok || v != w {
+					t.Fatalf("Get(%d) = %d, %v, want %d", i, v, ok, w)
+				}
+			}
+			if popped != 1+2+3 {
+				t.Errorf("OnPop reported %d elements, want 6", popped)
+			}
+
+			if n := RemoveAll(cp, 2); n != 1 || cp.Len() != len(want)-1 {
+				t.Errorf("RemoveAll of the head value = %d, size %d", n, cp.Len())
+			}
+			assertInvariants(t, cp, 3)
+		})
+	}
+}
+
+func TestRangeFuncIterators(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	var want []int
+	for i := 0; i < 40; i++ {
+		chunk := make([]int, 1+i%9)
+		for j := range chunk {
+			chunk[j] = len(want)
+			want = append(want, len(want))
+		}
+		cp.Push(chunk)
+	}
+
+	var got []int
+	for v := range cp.Values() {
+		got = append(got, v)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Values() = %v, want %v", got, want)
+	}
+
+	for i, v := range cp.All() {
+		if v != want[i] {
+			t.Fatalf("All() yielded (%d, %d), want (%d, %d)", i, v, i, want[i])
+		}
+	}
+
+	got = got[:0]
+	for v := range cp.Backward() {
+		got = append(got, v)
+	}
+	slices.Reverse(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("Backward() reversed = %v, want %v", got, want)
+	}
+
+	n := 0
+	for range cp.Backward() {
+		if n++; n == 5 {
+			break
+		}
+	}
+	if n != 5 {
+		t.Errorf("break after 5 elements yielded %d", n)
+	}
+
+	// 迴圈主體修改管道不會死鎖
+	for v := range cp.Values() {
+		if v%2 == 0 {
+			cp.PopFront()
+		}
+	}
+	for i, v := range cp.All() {
+		if i > 3 {
+			break
+		}
+		cp.Push([]int{v})
+	}
+	for range cp.Backward() {
+		cp.PopEnd()
+	}
+	if cp.Len() != 0 {
+		t.Errorf("PopEnd inside Backward should empty the pipe, %d left", cp.Len())
+	}
+}
+
+func TestChunksIterator(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	var chunks [][]int
+	next := 0
+	for _, n := range []int{10, 9, 12, 11} {
+		c := make([]int, n)
+		for j := range c {
+			c[j] = next
+			next++
+		}
+		chunks = append(chunks, c)
+		cp.Push(c)
+	}
+
+	var got [][]int
+	for c := range cp.Chunks() {
+		got = append(got, c)
+	}
+	if !slices.EqualFunc(got, chunks, slices.Equal[[]int]) {
+		t.Errorf("Chunks() = %v, want %v", got, chunks)
+	}
+
+	// 鎖在兩次 yield 之間釋放，迴圈中可以修改管道
+	var vals []int
+	for c := range cp.Chunks() {
+		vals = append(vals, c...)
+		if c[0] == 0 {
+			cp.PopChunkFront()
+			cp.PopChunkFront()
+			cp.PopFront()
+			cp.Push([]int{next})
+		}
+	}
+	// 第二個區塊在走訪前已被彈出，第三個區塊的首個元素也已被彈出
+	want := slices.Concat(chunks[0], chunks[2][1:], chunks[3], []int{next})
+	if !slices.Equal(vals, want) {
+		t.Errorf("Chunks() with mutation = %v, want %v", vals, want)
+	}
+
+	n := 0
+	for range cp.Chunks() {
+		n++
+		break
+	}
+	if n != 1 {
+		t.Errorf("break should stop after one chunk, got %d", n)
+	}
+	for range NewChunkPipe[int]().Chunks() {
+		t.Error("empty pipe should yield no chunks")
+	}
+}
+
+func TestPeek(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	if _, ok := cp.PeekFront(); ok {
+		t.Error("PeekFront on empty pipe should fail")
+	}
+	if _, ok := cp.PeekEnd(); ok {
+		t.Error("PeekEnd on empty pipe should fail")
+	}
+	if got := cp.PeekN(3); len(got) != 0 {
+		t.Errorf("PeekN on empty pipe = %v", got)
+	}
+
+	cp.Push([]int{1, 2, 3})
+	cp.Push([]int{4, 5, 6, 7, 8, 9, 10, 11, 12})
+	cp.PopFront()
+
+	if v, ok := cp.PeekFront(); !ok || v != 2 {
+		t.Errorf("PeekFront() = %d, %v, want 2, true", v, ok)
+	}
+	if v, ok := cp.PeekEnd(); !ok || v != 12 {
+		t.Errorf("PeekEnd() = %d, %v, want 12, true", v, ok)
+	}
+	if got := cp.PeekN(4); !slices.Equal(got, []int{2, 3, 4, 5}) {
+		t.Errorf("PeekN(4) = %v", got)
+	}
+	if got := cp.PeekN(100); len(got) != 11 || got[10] != 12 {
+		t.Errorf("PeekN(100) = %v", got)
+	}
+	if got := cp.PeekN(-1); len(got) != 0 {
+		t.Errorf("PeekN(-1) = %v", got)
+	}
+	if cp.Len() != 11 {
+		t.Errorf("peeking should not consume, size = %d", cp.Len())
+	}
+}
+
+func TestPushCopyAndOwned(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	src := make([]int, 32)
+	cp.PushCopy(src)
+	src[0] = 1
+	if v, _ := cp.Get(0); v != 0 {
+		t.Error("PushCopy should not alias the caller's slice")
+	}
+
+	owned := make([]int, 16, 64)
+	owned[0] = 7
+	cp.PushOwned(owned)
+	if got := cp.NumChunks(); got != 2 {
+		t.Fatalf("PushOwned should link a new chunk, got %d chunks", got)
+	}
+	// 小量推入會直接寫入交給管道的剩餘容量
+	cp.Push([]int{1, 2, 3})
+	if got := cp.NumChunks(); got != 2 {
+		t.Errorf("small push should reuse the owned chunk's capacity, got %d chunks", got)
+	}
+	if &owned[:17][16] != &cp.list[1].val[16] {
+		t.Error("PushOwned should keep the caller's backing array")
+	}
+	if v, _ := cp.Get(32); v != 7 {
+		t.Errorf("Get(32) = %d, want 7", v)
+	}
+	assertInvariants(t, cp, 0)
+
+	cp.PushCopy(nil).PushOwned(nil)
+	if cp.Len() != 51 {
+		t.Errorf("size = %d, want 51", cp.Len())
+	}
+}
+
+func TestByteChunkPipeReadWrite(t *testing.T) {
+	content := bytes.Repeat([]byte("chunkpipe streaming buffer "), 200)
+
+	b := NewByteChunkPipe(WithChunkSize(64))
+	if n, err := io.Copy(b, bytes.NewReader(content)); err != nil || n != int64(len(content)) {
+		t.Fatalf("io.Copy into pipe = %d, %v", n, err)
+	}
+	b.Close()
+	if err := iotest.TestReader(b, content); err != nil {
+		t.Fatal(err)
+	}
+	if b.Len() != 0 {
+		t.Errorf("reading everything should empty the pipe, %d left", b.Len())
+	}
+
+	var out bytes.Buffer
+	b = NewByteChunkPipe(WithChunkSize(64))
+	b.Write(content[:100])
+	p := make([]byte, 30)
+	if n, err := b.Read(p); n != 30 || err != nil || !bytes.Equal(p, content[:30]) {
+		t.Fatalf("Read = %d, %v, %q", n, err, p[:n])
+	}
+	// io.Copy 會等到 Close，關閉前的每次寫入都會被複製
+	copied := make(chan error, 1)
+	go func() {
+		n, err := io.Copy(&out, b)
+		if err == nil && n != 170 {
+			err = fmt.Errorf("copied %d bytes, want 170", n)
+		}
+		copied <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	b.Write(content[100:150])
+	time.Sleep(10 * time.Millisecond)
+	b.Write(content[150:200])
+	b.Close()
+	select {
+	case err := <-copied:
+		if err != nil {
+			t.Errorf("io.Copy out of pipe: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("io.Copy should return after Close")
+	}
+	if !bytes.Equal(out.Bytes(), content[30:200]) {
+		t.Errorf("io.Copy out of pipe wrote %q", out.Bytes())
+	}
+	if n, err := b.Read(p); n != 0 || err != io.EOF {
+		t.Errorf("Read on drained closed pipe = %d, %v, want 0, EOF", n, err)
+	}
+	if err := b.checkInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// chunkRecorder 記錄每次 Write 收到的切片長度
+type chunkRecorder struct {
+	bytes.Buffer
+	writes []int
+}
+
+func (r *chunkRecorder) Write(p []byte) (int, error) {
+	r.writes = append(r.writes, len(p))
+	return r.Buffer.Write(p)
+}
+
+// limitedWriter 在寫滿 n 個位元組後返回錯誤
+type limitedWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		w.Buffer.Write(p[:w.n])
+		n := w.n
+		w.n = 0
+		return n, io.ErrClosedPipe
+	}
+	w.n -= len(p)
+	return w.Buffer.Write(p)
+}
+
+func TestByteChunkPipeWriteToReadFrom(t *testing.T) {
+	content := make([]byte, 1000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+
+	b := NewByteChunkPipe(WithChunkSize(128))
+	if n, err := b.ReadFrom(&shortReader{data: content, n: 50, err: io.EOF}); err != nil || n != int64(len(content)) {
+		t.Fatalf("ReadFrom = %d, %v", n, err)
+	}
+	if got := b.ChunkLens(); len(got) != 8 || got[0] != 128 {
+		t.Errorf("ReadFrom chunk lengths = %v, want 128-byte chunks", got)
+	}
+
+	b.Close()
+	var rec chunkRecorder
+	if n, err := b.WriteTo(&rec); err != nil || n != int64(len(content)) {
+		t.Fatalf("WriteTo = %d, %v", n, err)
+	}
+	if !bytes.Equal(rec.Bytes(), content) {
+		t.Error("WriteTo wrote different bytes")
+	}
+	if !slices.Equal(rec.writes, []int{128, 128, 128, 128, 128, 128, 128, 104}) {
+		t.Errorf("WriteTo should write one chunk per call, got %v", rec.writes)
+	}
+	if b.Len() != 0 {
+		t.Errorf("WriteTo should drain the pipe, %d left", b.Len())
+	}
+
+	// 寫出失敗時只移除已寫出的位元組
+	b = NewByteChunkPipe(WithChunkSize(128))
+	b.Write(content)
+	lw := &limitedWriter{n: 300}
+	if n, err := b.WriteTo(lw); n != 300 || err != io.ErrClosedPipe {
+		t.Fatalf("WriteTo on failing writer = %d, %v", n, err)
+	}
+	if got := b.Bytes(); !bytes.Equal(got, content[300:]) {
+		t.Errorf("remaining %d bytes do not match the unwritten tail", len(got))
+	}
+	if err := b.checkInvariants(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPopCtx(t *testing.T) {
+	cp := NewChunkPipe[int]()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := cp.PopFrontCtx(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("PopFrontCtx on empty pipe = %v, want DeadlineExceeded", err)
+	}
+
+	cp.Push([]int{1, 2, 3})
+	if v, err := cp.PopEndCtx(context.Background()); err != nil || v != 3 {
+		t.Errorf("PopEndCtx() = %d, %v, want 3", v, err)
+	}
+	if v, err := cp.PopFrontCtx(context.Background()); err != nil || v != 1 {
+		t.Errorf("PopFrontCtx() = %d, %v, want 1", v, err)
+	}
+	cp.PopFront()
+
+	// 多個阻塞中的消費者，每個值只會被取得一次
+	const consumers, total = 4, 1000
+	results := make(chan int, total)
+	var wg sync.WaitGroup
+	for c := 0; c < consumers; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				v, err := cp.PopFrontCtx(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if v < 0 {
+					return
+				}
+				results <- v
+			}
+		}()
+	}
+	for i := 0; i < total; i++ {
+		cp.Push([]int{i})
+		if i%100 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	for c := 0; c < consumers; c++ {
+		cp.Push([]int{-1})
+	}
+	wg.Wait()
+	close(results)
+
+	seen := make([]bool, total)
+	n := 0
+	for v := range results {
+		if seen[v] {
+			t.Fatalf("value %d delivered twice", v)
+		}
+		seen[v] = true
+		n++
+	}
+	if n != total {
+		t.Errorf("delivered %d values, want %d", n, total)
+	}
+}
+
+func TestBounded(t *testing.T) {
+	cp := NewBounded[int](10)
+	if err := cp.TryPush([]int{1, 2, 3, 4, 5, 6, 7, 8}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.TryPush([]int{9, 10, 11}); !errors.Is(err, ErrFull) {
+		t.Fatalf("TryPush over the limit = %v, want ErrFull", err)
+	}
+	if cp.Len() != 8 {
+		t.Fatalf("failed TryPush should not push anything, size = %d", cp.Len())
+	}
+
+	done := make(chan struct{})
+	go func() {
+		cp.Push([]int{9, 10, 11, 12})
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("Push should block while the pipe is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+	if cp.Len() != 10 {
+		t.Errorf("blocked Push should fill the remaining space, size = %d", cp.Len())
+	}
+	cp.PopFront()
+	cp.PopFront()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Push should resume after pops")
+	}
+	if got := cp.ValueSlice(); !slices.Equal(got, []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Errorf("ValueSlice() = %v", got)
+	}
+
+	// 超過上限的單次推入會隨消費分段完成，順序不變
+	b := NewBounded[int](16, WithCopyOnPush(false))
+	src := make([]int, 1000)
+	for i := range src {
+		src[i] = i
+	}
+	go b.Push(src)
+	for i := 0; i < len(src); i++ {
+		v, err := b.PopFrontCtx(context.Background())
+		if err != nil || v != i {
+			t.Fatalf("PopFrontCtx() = %d, %v, want %d", v, err, i)
+		}
+		if n := b.Len(); n > 16 {
+			t.Fatalf("bounded pipe grew to %d elements", n)
+		}
+	}
+	assertInvariants(t, b, 0)
+
+	if err := NewChunkPipe[int]().TryPush(make([]int, 1<<12)); err != nil {
+		t.Errorf("TryPush on an unbounded pipe = %v", err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	cp.Push([]int{1, 2})
+
+	waiter := NewChunkPipe[int]()
+	errc := make(chan error, 1)
+	go func() {
+		_, err := waiter.PopFrontCtx(context.Background())
+		errc <- err
+	}()
+
+	bounded := NewBounded[int](1)
+	bounded.Push([]int{0})
+	pushed := make(chan struct{})
+	go func() {
+		bounded.Push([]int{1, 2})
+		close(pushed)
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	for _, p := range []*ChunkPipe[int]{cp, waiter, bounded} {
+		if err := p.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cp.Close(); err != nil {
+		t.Errorf("second Close = %v", err)
+	}
+	if !cp.Closed() {
+		t.Error("Closed() should report true")
+	}
+
+	select {
+	case err := <-errc:
+		if err != io.EOF {
+			t.Errorf("blocked PopFrontCtx after Close = %v, want io.EOF", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close should wake blocked pops")
+	}
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("Close should wake blocked pushes")
+	}
+	if got := bounded.ValueSlice(); !slices.Equal(got, []int{0}) {
+		t.Errorf("bounded pipe after Close = %v", got)
+	}
+
+	cp.Push([]int{3}).PushCopy([]int{4}).PushOwned([]int{5}).PushAll([]int{6})
+	if err := cp.TryPush([]int{7}); !errors.Is(err, ErrClosed) {
+		t.Errorf("TryPush after Close = %v, want ErrClosed", err)
+	}
+	for _, want := range []int{1, 2} {
+		if v, err := cp.PopFrontCtx(context.Background()); err != nil || v != want {
+			t.Errorf("PopFrontCtx() = %d, %v, want %d", v, err, want)
+		}
+	}
+	if _, err := cp.PopEndCtx(context.Background()); err != io.EOF {
+		t.Errorf("PopEndCtx on drained closed pipe = %v, want io.EOF", err)
+	}
+
+	b := NewByteChunkPipe()
+	type result struct {
+		s   string
+		err error
+	}
+	readc := make(chan result, 1)
+	read := func() {
+		p := make([]byte, 8)
+		n, err := b.Read(p)
+		readc <- result{string(p[:n]), err}
+	}
+	go read()
+	time.Sleep(10 * time.Millisecond)
+	select {
+	case r := <-readc:
+		t.Fatalf("Read on empty open pipe = %q, %v, want it to block", r.s, r.err)
+	default:
+	}
+	b.WriteString("hi")
+	select {
+	case r := <-readc:
+		if r.s != "hi" || r.err != nil {
+			t.Errorf("blocked Read after Write = %q, %v", r.s, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Write should wake a blocked Read")
+	}
+	go read()
+	time.Sleep(10 * time.Millisecond)
+	b.Close()
+	select {
+	case r := <-readc:
+		if r.s != "" || r.err != io.EOF {
+			t.Errorf("blocked Read after Close = %q, %v, want io.EOF", r.s, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Close should wake a blocked Read")
+	}
+
+	b = NewByteChunkPipe()
+	b.WriteString("bye")
+	b.Close()
+	if _, err := b.WriteString("!"); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteString after Close = %v", err)
+	}
+	if err := b.WriteByte('!'); !errors.Is(err, ErrClosed) {
+		t.Errorf("WriteByte after Close = %v", err)
+	}
+	if got, err := io.ReadAll(b); err != nil || string(got) != "bye" {
+		t.Errorf("ReadAll after Close = %q, %v", got, err)
+	}
+	if _, err := b.ReadFrom(bytes.NewReader([]byte("x"))); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadFrom after Close = %v", err)
+	}
+}
+
+func TestReadableWritable(t *testing.T) {
+	ready := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+
+	cp := NewBounded[int](2)
+	if ready(cp.Readable()) {
+		t.Error("empty pipe should not be readable")
+	}
+	if !ready(cp.Writable()) {
+		t.Error("empty bounded pipe should be writable")
+	}
+
+	r := cp.Readable()
+	cp.Push([]int{1, 2})
+	if !ready(r) || !ready(cp.Readable()) {
+		t.Error("Readable should fire after Push")
+	}
+	w := cp.Writable()
+	if ready(w) {
+		t.Error("full pipe should not be writable")
+	}
+
+	// 與其他事件一起 select
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cp.PopFront()
+	}()
+	select {
+	case <-w:
+	case <-time.After(time.Second):
+		t.Fatal("Writable should fire after a pop")
+	}
+
+	if !ready(NewChunkPipe[int]().Writable()) {
+		t.Error("unbounded pipe should always be writable")
+	}
+	empty := NewChunkPipe[int]()
+	r = empty.Readable()
+	empty.Close()
+	if !ready(r) {
+		t.Error("Close should make the pipe readable so consumers see EOF")
+	}
+}
+
+func TestLenCapIsEmpty(t *testing.T) {
+	cp := New[int](WithChunkSize(16))
+	if cp.Len() != 0 || cp.Cap() != 0 || !cp.IsEmpty() {
+		t.Fatalf("new pipe: Len %d, Cap %d, IsEmpty %v", cp.Len(), cp.Cap(), cp.IsEmpty())
+	}
+
+	cp.Push([]int{1, 2, 3})
+	if cp.Len() != 3 || cp.Cap() != 16 || cp.IsEmpty() {
+		t.Errorf("after push: Len %d, Cap %d, IsEmpty %v", cp.Len(), cp.Cap(), cp.IsEmpty())
+	}
+	cp.PopFront()
+	if cp.Len() != 2 || cp.Cap() != cp.Stats().AllocatedElements {
+		t.Errorf("after pop: Len %d, Cap %d, Stats %+v", cp.Len(), cp.Cap(), cp.Stats())
+	}
+	cp.PopChunkFront()
+	if cp.Len() != 0 || cp.Cap() != 0 || !cp.IsEmpty() {
+		t.Errorf("after draining: Len %d, Cap %d, IsEmpty %v", cp.Len(), cp.Cap(), cp.IsEmpty())
+	}
+}
+
+func TestClear(t *testing.T) {
+	cp := New[int](WithChunkSize(64))
+	popped := 0
+	cp.OnPop(func(n int) { popped += n })
+	for i := 0; i < 100; i++ {
+		cp.Push([]int{i})
+	}
+	cp.PopFront()
+	cur := cp.Cursor()
+	cur.Next()
+
+	cp.Clear()
+	if !cp.IsEmpty() || cp.Len() != 0 || cp.NumChunks() != 0 {
+		t.Fatalf("after Clear: Len %d, chunks %d", cp.Len(), cp.NumChunks())
+	}
+	if popped != 100 {
+		t.Errorf("OnPop reported %d elements, want 100", popped)
+	}
+	assertInvariants(t, cp, 0)
+
+	// 清空後可以繼續使用，舊的游標不會看到新推入的元素之前的位置
+	cp.Push([]int{7, 8, 9})
+	if got := cp.ValueSlice(); !slices.Equal(got, []int{7, 8, 9}) {
+		t.Errorf("ValueSlice() after Clear = %v", got)
+	}
+	if v, ok := cur.Next(); !ok || v != 7 {
+		t.Errorf("cursor after Clear = %d, %v, want 7, true", v, ok)
+	}
+	assertInvariants(t, cp, 1)
+
+	// 回收的陣列會在之後的推入中被重複使用（競態偵測模式下池可能丟棄，允許重試）
+	reused := false
+	for i := 0; i < 20 && !reused; i++ {
+		cp.Clear()
+		cp.Push([]int{i})
+		prev := &cp.list[0].val[0]
+		cp.Clear()
+		cp.Push([]int{i})
+		reused = &cp.list[0].val[0] == prev
+	}
+	if !reused {
+		t.Error("Clear should recycle owned chunks")
+	}
+}
+
+func TestPushFront(t *testing.T) {
+	cp := NewChunkPipe[int]()
+	cp.PushFrontOne(3)
+	cp.Push([]int{4, 5})
+	cp.PushFront([]int{1, 2})
+	cp.PushFront(nil)
+	if got := cp.ValueSlice(); !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Fatalf("ValueSlice() = %v", got)
+	}
+	for i := 0; i < 5; i++ {
+		if v, ok := cp.Get(i); !ok || v != i+1 {
+			t.Errorf("Get(%d) = %d, %v", i, v, ok)
+		}
+	}
+	if v, _ := cp.PopFront(); v != 1 {
+		t.Errorf("PopFront() = %d, want 1", v)
+	}
+	assertInvariants(t, cp, 0)
+
+	// 作為雙端佇列使用
+	dq := NewChunkPipe[int]()
+	for i := 0; i < 100; i++ {
+		if i%2 == 0 {
+			dq.PushFrontOne(i)
+		} else {
+			dq.Push([]int{i})
+		}
+	}
+	for i := 98; i >= 0; i -= 2 {
+		if v, _ := dq.PopFront(); v != i {
+			t.Fatalf("PopFront() = %d, want %d", v, i)
+		}
+	}
+	assertInvariants(t, dq, 1)
+
+	// 有上限的管道分段推入頭部時順序不變
+	b := NewBounded[int](4)
+	b.Push([]int{100})
+	done := make(chan struct{})
+	go func() {
+		b.PushFront([]int{1, 2, 3, 4, 5, 6})
+		close(done)
+	}()
+	var got []int
+	for len(got) < 7 {
+		v, err := b.PopEndCtx(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	<-done
+	if !slices.Equal(got, []int{100, 6, 5, 4, 3, 2, 1}) {
+		t.Errorf("bounded PushFront order = %v", got)
+	}
+}
+
+func TestPushOne(t *testing.T) {
+	cp := New[int](WithChunkSize(64))
+	for i := 0; i < 200; i++ {
+		cp.PushOne(i)
+	}
+	if got := cp.ChunkLens(); !slices.Equal(got, []int{64, 64, 64, 8}) {
+		t.Errorf("PushOne chunk lengths = %v, want 64-element chunks", got)
+	}
+	for i := 0; i < 200; i++ {
+		if v, ok := cp.Get(i); !ok || v != i {
+			t.Fatalf("Get(%d) = %d, %v", i, v, ok)
+		}
+	}
+	assertInvariants(t, cp, 0)
+
+	// 穩定狀態下只有換區塊時才配置記憶體
+	if n := testing.AllocsPerRun(1000, func() { cp.PushOne(1) }); n > 0.1 {
+		t.Errorf("PushOne allocated %v times per call", n)
+	}
+
+	b := NewBounded[int](2)
+	b.PushOne(1).PushOne(2)
+	if err := b.TryPush([]int{3}); !errors.Is(err, ErrFull) {
+		t.Errorf("bounded pipe should be full after two PushOne calls, got %v", err)
+	}
+}
+
+func TestPopFrontEndN(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	var want []int
+	for i := 0; i < 5; i++ {
+		chunk := make([]int, 10)
+		for j := range chunk {
+			chunk[j] = len(want)
+			want = append(want, len(want))
+		}
+		cp.Push(chunk)
+	}
+
+	if got, ok := cp.PopFrontN(15); !ok || !slices.Equal(got, want[:15]) {
+		t.Errorf("PopFrontN(15) = %v, %v", got, ok)
+	}
+	if got, ok := cp.PopEndN(12); !ok || !slices.Equal(got, want[38:]) {
+		t.Errorf("PopEndN(12) = %v, %v", got, ok)
+	}
+	// 跨越區塊後未取完的區塊仍留在原處
+	if got := cp.ChunkLens(); !slices.Equal(got, []int{5, 10, 8}) {
+		t.Errorf("ChunkLens() = %v, want [5 10 8]", got)
+	}
+	if _, ok := cp.PopFrontN(24); ok {
+		t.Error("PopFrontN beyond Len should fail")
+	}
+	if _, ok := cp.PopEndN(-1); ok {
+		t.Error("PopEndN(-1) should fail")
+	}
+	if cp.Len() != 23 {
+		t.Errorf("failed pops should not consume, Len = %d", cp.Len())
+	}
+	if got, ok := cp.PopFrontN(0); !ok || len(got) != 0 {
+		t.Errorf("PopFrontN(0) = %v, %v", got, ok)
+	}
+	if got, ok := cp.PopEndN(23); !ok || !slices.Equal(got, want[15:38]) || !cp.IsEmpty() {
+		t.Errorf("PopEndN(23) = %v, %v", got, ok)
+	}
+	assertInvariants(t, cp, 0)
+}
+
+func TestPopFrontInto(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push([]int{10, 11, 12})
+
+	buf := make([]int, 4)
+	var got []int
+	for {
+		n := cp.PopFrontInto(buf)
+		if n == 0 {
+			break
+		}
+		got = append(got, buf[:n]...)
+	}
+	if !slices.Equal(got, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Errorf("PopFrontInto collected %v", got)
+	}
+	if !cp.IsEmpty() {
+		t.Error("pipe should be drained")
+	}
+	if n := cp.PopFrontInto(nil); n != 0 {
+		t.Errorf("PopFrontInto(nil) = %d", n)
+	}
+
+	cp.Push(make([]int, 100))
+	if n := testing.AllocsPerRun(10, func() { cp.PopFrontInto(buf) }); n != 0 {
+		t.Errorf("PopFrontInto allocated %v times", n)
+	}
+	assertInvariants(t, cp, 0)
+}
+
+func TestPeekChunkFrontDiscard(t *testing.T) {
+	cp := New[byte](WithCopyOnPush(false))
+	cp.Push([]byte("header:1234;"))
+	cp.Push([]byte("body-and-more"))
+
+	// 以 Peek/Discard 解析，不複製資料
+	view, ok := cp.PeekChunkFront()
+	if !ok || string(view) != "header:1234;" {
+		t.Fatalf("PeekChunkFront() = %q, %v", view, ok)
+	}
+	if n := cp.Discard(7); n != 7 {
+		t.Errorf("Discard(7) = %d", n)
+	}
+	view, _ = cp.PeekChunkFront()
+	if string(view) != "1234;" {
+		t.Errorf("PeekChunkFront() after Discard = %q", view)
+	}
+	if cap(view) != len(view) {
+		t.Error("view capacity should be limited to its length")
+	}
+	if n := cp.Discard(10); n != 10 {
+		t.Errorf("Discard across chunks = %d", n)
+	}
+	if got := string(cp.ValueSlice()); got != "and-more" {
+		t.Errorf("remaining = %q", got)
+	}
+	if n := cp.Discard(100); n != 8 || !cp.IsEmpty() {
+		t.Errorf("Discard(100) = %d, Len %d", n, cp.Len())
+	}
+	if n := cp.Discard(-1); n != 0 {
+		t.Errorf("Discard(-1) = %d", n)
+	}
+	if _, ok := cp.PeekChunkFront(); ok {
+		t.Error("PeekChunkFront on empty pipe should fail")
+	}
+}
+
+func TestInsertRemoveAt(t *testing.T) {
+	for _, alias := range []bool{false, true} {
+		t.Run(fmt.Sprintf("alias=%v", alias), func(t *testing.T) {
+			cp := New[int](WithCopyOnPush(!alias))
+			ref := make([]int, 30)
+			for i := range ref {
+				ref[i] = i
+			}
+			cp.Push(slices.Clone(ref[:15]))
+			cp.Push(slices.Clone(ref[15:]))
+
+			for _, op := range []struct {
+				index int
+				data  []int
+			}{{0, []int{-1}}, {16, []int{-2, -3}}, {33, []int{-4}}, {7, nil}} {
+				if !cp.Insert(op.index, op.data) {
+					t.Fatalf("Insert(%d) failed", op.index)
+				}
+				ref = slices.Insert(ref, op.index, op.data...)
+			}
+			if cp.Insert(cp.Len()+1, []int{1}) || cp.Insert(-1, []int{1}) {
+				t.Error("Insert out of range should fail")
+			}
+
+			// 負數表示從尾端起算
+			for _, index := range []int{5, 0, 20, -2} {
+				if index < 0 {
+					index += len(ref)
+				}
+				v, ok := cp.RemoveAt(index)
+				if !ok || v != ref[index] {
+					t.Fatalf("RemoveAt(%d) = %d, %v, want %d", index, v, ok, ref[index])
+				}
+				ref = slices.Delete(ref, index, index+1)
+			}
+			if _, ok := cp.RemoveAt(len(ref)); ok {
+				t.Error("RemoveAt out of range should fail")
+			}
+
+			if got := cp.ValueSlice(); !slices.Equal(got, ref) {
+				t.Fatalf("ValueSlice() = %v, want %v", got, ref)
+			}
+			for i, w := range ref {
+				if v, _ := cp.Get(i); v != w {
+					t.Fatalf("Get(%d) = %d, want %d", i, v, w)
+				}
+			}
+			assertInvariants(t, cp, 0)
+		})
+	}
+}
+
+func TestSliceSubPipe(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	var want []int
+	for i := 0; i < 4; i++ {
+		chunk := make([]int, 10)
+		for j := range chunk {
+			chunk[j] = len(want)
+			want = append(want, len(want))
+		}
+		cp.Push(chunk)
+	}
+	cp.PopFront()
+	want = want[1:]
+
+	if got := cp.Slice(5, 27); !slices.Equal(got, want[5:27]) {
+		t.Errorf("Slice(5, 27) = %v", got)
+	}
+	if got := cp.Slice(3, 3); got == nil || len(got) != 0 {
+		t.Errorf("Slice(3, 3) = %v, want empty", got)
+	}
+	if cp.Slice(-1, 2) != nil || cp.Slice(0, 100) != nil || cp.Slice(5, 4) != nil {
+		t.Error("Slice with an invalid range should return nil")
+	}
+
+	sub := cp.SubPipe(5, 27)
+	if got := sub.ValueSlice(); !slices.Equal(got, want[5:27]) {
+		t.Fatalf("SubPipe(5, 27) = %v", got)
+	}
+	if got := sub.ChunkLens(); !slices.Equal(got, []int{4, 10, 8}) {
+		t.Errorf("SubPipe should share the chunk layout, got %v", got)
+	}
+	if v, ok := sub.Get(0); !ok || v != want[5] {
+		t.Errorf("sub.Get(0) = %d, %v", v, ok)
+	}
+	assertInvariants(t, sub, 0)
+
+	// 子管道的推入不會寫入來源的記憶體
+	sub.Push([]int{-1})
+	sub.PopFront()
+	if got := cp.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("source changed after using the sub pipe: %v", got)
+	}
+	assertInvariants(t, cp, 1)
+
+	// 來源取空區塊並繼續推入時，不會重複使用子管道仍引用的記憶體
+	src := New[int](WithChunkSize(8))
+	for i := 0; i < 16; i++ {
+		src.PushOne(i)
+	}
+	view := src.SubPipe(2, 6)
+	src.FillRange(0, 8, -1)
+	src.Discard(8)
+	for i := 0; i < 16; i++ {
+		src.PushOne(100 + i)
+	}
+	if got := view.ValueSlice(); !slices.Equal(got, []int{2, 3, 4, 5}) {
+		t.Errorf("sub pipe changed after the source reused memory: %v", got)
+	}
+	if sub := cp.SubPipe(0, 0); sub == nil || !sub.IsEmpty() {
+		t.Error("SubPipe(0, 0) should be an empty pipe")
+	}
+	if cp.SubPipe(0, 100) != nil {
+		t.Error("SubPipe with an invalid range should return nil")
+	}
+}
+
+func TestCloneAndCloneShared(t *testing.T) {
+	build := func() (*ChunkPipe[int], []int) {
+		cp := New[int](WithChunkSize(8))
+		var want []int
+		for i := 0; i < 20; i++ {
+			cp.PushOne(i)
+			want = append(want, i)
+		}
+		return cp, want
+	}
+
+	cp, want := build()
+	deep := cp.Clone()
+	cp.FillRange(0, 5, -1)
+	if got := deep.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("Clone changed with its source: %v", got)
+	}
+	assertInvariants(t, deep, 0)
+
+	cp, want = build()
+	cow := cp.CloneShared()
+	if &cow.list[0].val[0] != &cp.list[0].val[0] {
+		t.Fatal("CloneShared should not copy chunks")
+	}
+	assertInvariants(t, cp, 1)
+	assertInvariants(t, cow, 2)
+
+	// 任一方的修改都不影響另一方
+	cp.FillRange(0, 3, -1)
+	cp.PushOne(100)
+	cow.FillRange(10, 12, -2)
+	cow.PushOne(200)
+	cow.PopFront()
+	cp.RemoveAt(9)
+	Remove(cow, 15)
+	RemoveAll(cp, 17)
+	cp.PopEnd()
+	cow.Insert(5, []int{-3})
+
+	wantCP := slices.Clone(want)
+	for i := 0; i < 3; i++ {
+		wantCP[i] = -1
+	}
+	wantCP = append(wantCP, 100)
+	wantCP = slices.Delete(wantCP, 9, 10)
+	wantCP = slices.DeleteFunc(wantCP, func(v int) bool { return v == 17 })
+	wantCP = wantCP[:len(wantCP)-1]
+
+	wantCOW := slices.Clone(want)
+	wantCOW[10], wantCOW[11] = -2, -2
+	wantCOW = append(wantCOW[1:], 200)
+	wantCOW = slices.DeleteFunc(wantCOW, func(v int) bool { return v == 15 })
+	wantCOW = slices.Insert(wantCOW, 5, -3)
+
+	if got := cp.ValueSlice(); !slices.Equal(got, wantCP) {
+		t.Errorf("source = %v, want %v", got, wantCP)
+	}
+	if got := cow.ValueSlice(); !slices.Equal(got, wantCOW) {
+		t.Errorf("clone = %v, want %v", got, wantCOW)
+	}
+	assertInvariants(t, cp, 3)
+	assertInvariants(t, cow, 4)
+
+	// 指標型別彈出時不會清除另一方仍在使用的 slots
+	ptrs := NewChunkPipe[*int]()
+	x := 1
+	ptrs.Push([]*int{&x, &x, &x})
+	shared := ptrs.CloneShared()
+	ptrs.PopFront()
+	ptrs.PopEnd()
+	for i := 0; i < 3; i++ {
+		if v, _ := shared.Get(i); v != &x {
+			t.Fatalf("shared clone slot %d was cleared", i)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	a := New[int](WithCopyOnPush(false))
+	b := New[int](WithCopyOnPush(false))
+	a.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	a.PopFront()
+	src := []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}
+	b.Push(src)
+	b.PushOne(20)
+
+	var pushed, popped int
+	a.OnPush(func(n int) {
+		pushed += n
+		b.Len() // 回呼在兩個鎖都釋放後才執行
+	})
+	b.OnPop(func(n int) {
+		popped += n
+		a.Len()
+	})
+
+	if n := a.Append(b); n != 11 {
+		t.Fatalf("Append() = %d, want 11", n)
+	}
+	if &a.list[1].val[0] != &src[0] {
+		t.Error("Append should relink chunks without copying")
+	}
+	want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19, 20}
+	if got := a.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("after Append = %v", got)
+	}
+	if !b.IsEmpty() || pushed != 11 || popped != 11 {
+		t.Errorf("b.Len %d, pushed %d, popped %d", b.Len(), pushed, popped)
+	}
+	assertInvariants(t, a, 0)
+	assertInvariants(t, b, 1)
+
+	// 清空後的來源可以繼續使用
+	b.Push([]int{1})
+	if v, _ := b.Get(0); v != 1 {
+		t.Error("source pipe should be reusable after Append")
+	}
+	if a.Append(a) != 0 || a.Append(nil) != 0 || a.Append(NewChunkPipe[int]()) != 0 {
+		t.Error("Append of itself, nil or an empty pipe should move nothing")
+	}
+
+	bounded := NewBounded[int](3)
+	bounded.Push([]int{1, 2})
+	if n := bounded.Append(b); n != 1 {
+		t.Errorf("Append within the limit = %d", n)
+	}
+	b.Push([]int{2})
+	if n := bounded.Append(b); n != 0 || b.Len() != 1 {
+		t.Errorf("Append over the limit = %d, source Len %d", n, b.Len())
+	}
+}
+
+func TestSplit(t *testing.T) {
+	cp := New[int](WithChunkSize(8))
+	var want []int
+	for i := 0; i < 30; i++ {
+		cp.PushOne(i)
+		want = append(want, i)
+	}
+	cp.PopFront()
+	want = want[1:]
+
+	for _, index := range []int{0, 5, 7, 15, len(want)} {
+		src := cp.Clone()
+		left, right := src.Split(index)
+		if left != src || right == nil {
+			t.Fatalf("Split(%d) = %p, %p", index, left, right)
+		}
+		if got := left.ValueSlice(); !slices.Equal(got, want[:index]) {
+			t.Errorf("Split(%d) left = %v", index, got)
+		}
+		if got := right.ValueSlice(); !slices.Equal(got, want[index:]) {
+			t.Errorf("Split(%d) right = %v", index, got)
+		}
+		assertInvariants(t, left, index)
+		assertInvariants(t, right, index)
+
+		// 兩邊之後的推入與修改互不影響
+		left.Push([]int{-1, -2})
+		right.PushFront([]int{-3})
+		if index < len(want) {
+			if v, _ := right.Get(1); v != want[index] {
+				t.Errorf("Split(%d): right[1] = %d after pushing into left", index, v)
+			}
+		}
+	}
+
+	chunked := New[int](WithCopyOnPush(false))
+	data := make([]int, 40)
+	chunked.Push(data[:20])
+	chunked.Push(data[20:])
+	_, right := chunked.Split(25)
+	if &right.list[0].val[0] != &data[25] {
+		t.Error("Split should not copy elements")
+	}
+	if _, r := chunked.Split(26); r != nil {
+		t.Error("Split beyond Len should return nil")
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	src := NewChunkPipe[int]()
+	dst := NewChunkPipe[int]()
+	dst.Push([]int{0})
+	src.Push([]int{1, 2, 3})
+	if n := src.MoveTo(dst); n != 3 || !src.IsEmpty() {
+		t.Fatalf("MoveTo() = %d, src Len %d", n, src.Len())
+	}
+	if got := dst.ValueSlice(); !slices.Equal(got, []int{0, 1, 2, 3}) {
+		t.Errorf("dst = %v", got)
+	}
+
+	// 兩個方向同時交接不會死鎖，元素總數不變
+	var wg sync.WaitGroup
+	for g := 0; g < 2; g++ {
+		from, to := src, dst
+		if g == 1 {
+			from, to = dst, src
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				from.PushOne(i)
+				from.MoveTo(to)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := src.Len() + dst.Len(); n != 2004 {
+		t.Errorf("total elements after concurrent MoveTo = %d, want 2004", n)
+	}
+	assertInvariants(t, src, 0)
+	assertInvariants(t, dst, 1)
+}
+
+func TestDrainPopAll(t *testing.T) {
+	cp := New[int](WithChunkSize(8))
+	for i := 0; i < 50; i++ {
+		cp.PushOne(i)
+	}
+	cp.PopFront()
+
+	var got []int
+	cp.Drain(func(v int) {
+		got = append(got, v)
+		if v == 10 {
+			cp.PushOne(-1) // fn 在鎖外執行
+		}
+	})
+	if len(got) != 49 || got[0] != 1 || got[48] != 49 {
+		t.Errorf("Drain collected %v", got)
+	}
+	if got := cp.ValueSlice(); !slices.Equal(got, []int{-1}) {
+		t.Errorf("elements pushed during Drain = %v, want [-1]", got)
+	}
+	assertInvariants(t, cp, 0)
+
+	cp.Push([]int{1, 2, 3})
+	if got := cp.PopAll(); !slices.Equal(got, []int{-1, 1, 2, 3}) {
+		t.Errorf("PopAll() = %v", got)
+	}
+	if !cp.IsEmpty() {
+		t.Error("PopAll should empty the pipe")
+	}
+	if got := cp.PopAll(); got == nil || len(got) != 0 {
+		t.Errorf("PopAll on empty pipe = %v", got)
+	}
+	cp.Drain(func(int) { t.Error("Drain on empty pipe should not call fn") })
+	assertInvariants(t, cp, 1)
+}
+
+func TestSetAt(t *testing.T) {
+	cp := New[int](WithChunkSize(4))
+	for i := 0; i < 10; i++ {
+		cp.PushOne(i)
+	}
+	cp.PopFront()
+
+	if !cp.Set(0, 100) || !cp.Set(8, 108) {
+		t.Fatal("Set on valid index should succeed")
+	}
+	if cp.Set(-1, 0) || cp.Set(9, 0) {
+		t.Error("Set on invalid index should fail")
+	}
+	if p := cp.At(9); p != nil {
+		t.Errorf("At(9) = %v, want nil", p)
+	}
+	*cp.At(4) *= 10
+	if got, want := cp.ValueSlice(), []int{100, 2, 3, 4, 50, 6, 7, 8, 108}; !slices.Equal(got, want) {
+		t.Errorf("content = %v, want %v", got, want)
+	}
+
+	// 寫入共用區塊時先複製，不影響另一方
+	cow := cp.CloneShared()
+	cow.Set(1, -1)
+	*cow.At(2) = -2
+	if v, _ := cp.Get(1); v != 2 {
+		t.Errorf("Set on CloneShared leaked to source: %d", v)
+	}
+	if v, _ := cp.Get(2); v != 3 {
+		t.Errorf("At on CloneShared leaked to source: %d", v)
+	}
+	assertInvariants(t, cp, 0)
+	assertInvariants(t, cow, 1)
+}
+
+func TestGetRange(t *testing.T) {
+	cp := New[int](WithChunkSize(4))
+	for i := 0; i < 20; i++ {
+		cp.PushOne(i)
+	}
+	cp.PopFront()
+
+	got, ok := cp.GetRange(2, 10, nil)
+	if !ok || !slices.Equal(got, []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Errorf("GetRange(2, 10) = %v, %v", got, ok)
+	}
+
+	// 附加到呼叫者提供的切片，容量足夠時不配置
+	buf := make([]int, 1, 16)
+	buf[0] = -1
+	got, ok = cp.GetRange(15, 4, buf)
+	if !ok || !slices.Equal(got, []int{-1, 16, 17, 18, 19}) || &got[0] != &buf[0] {
+		t.Errorf("GetRange into buffer = %v, %v", got, ok)
+	}
+	if n := testing.AllocsPerRun(100, func() { cp.GetRange(0, 8, buf[:0]) }); n != 0 {
+		t.Errorf("GetRange into a large enough buffer allocated %v times", n)
+	}
+
+	if got, ok := cp.GetRange(19, 0, nil); !ok || len(got) != 0 {
+		t.Errorf("GetRange(19, 0) = %v, %v", got, ok)
+	}
+	for _, r := range [][2]int{{-1, 2}, {18, 2}, {3, -1}, {20, 0}} {
+		if got, ok := cp.GetRange(r[0], r[1], buf[:1]); ok || len(got) != 1 {
+			t.Errorf("GetRange(%d, %d) = %v, %v, want failure", r[0], r[1], got, ok)
+		}
+	}
+}
+
+func TestCopyTo(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push([]int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+	cp.PopFront()
+
+	dst := make([]int, 6)
+	if n := cp.CopyTo(dst, 6); n != 6 || !slices.Equal(dst, []int{7, 8, 9, 10, 11, 12}) {
+		t.Errorf("CopyTo(dst, 6) = %d, %v", n, dst)
+	}
+	if n := cp.CopyTo(dst, 16); n != 3 || !slices.Equal(dst[:n], []int{17, 18, 19}) {
+		t.Errorf("CopyTo near the end = %d, %v", n, dst[:n])
+	}
+	for _, off := range []int{-1, 19, 100} {
+		if n := cp.CopyTo(dst, off); n != 0 {
+			t.Errorf("CopyTo(dst, %d) = %d, want 0", off, n)
+		}
+	}
+	if n := cp.CopyTo(nil, 0); n != 0 {
+		t.Errorf("CopyTo(nil, 0) = %d, want 0", n)
+	}
+}
+
+func TestSwapReverse(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push([]int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+	cp.Push([]int{20, 21, 22, 23, 24, 25, 26, 27, 28, 29})
+	cp.PopFront()
+	cp.PopEnd()
+	want := make([]int, 28)
+	for i := range want {
+		want[i] = i + 1
+	}
+
+	if !cp.Swap(0, 27) || !cp.Swap(5, 5) {
+		t.Fatal("Swap on valid indices should succeed")
+	}
+	want[0], want[27] = want[27], want[0]
+	if cp.Swap(-1, 0) || cp.Swap(0, 28) {
+		t.Error("Swap on invalid index should fail")
+	}
+	if got := cp.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("after Swap = %v, want %v", got, want)
+	}
+
+	cp.Reverse()
+	slices.Reverse(want)
+	if got := cp.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("after Reverse = %v, want %v", got, want)
+	}
+	if got := cp.ChunkLens(); !slices.Equal(got, []int{9, 10, 9}) {
+		t.Errorf("Reverse should keep the chunk layout mirrored, got %v", got)
+	}
+	assertInvariants(t, cp, 0)
+
+	// 反轉後仍可從兩端正常推入與彈出
+	cp.PushOne(100)
+	if v, _ := cp.PopFront(); v != want[0] {
+		t.Errorf("PopFront after Reverse = %d, want %d", v, want[0])
+	}
+	if v, _ := cp.Get(3); v != want[4] {
+		t.Errorf("Get(3) after Reverse = %d, want %d", v, want[4])
+	}
+
+	// 共用區塊先複製再修改
+	cow := cp.CloneShared()
+	cow.Reverse()
+	cow.Swap(0, 1)
+	if got := cp.ValueSlice(); !slices.Equal(got, append(want[1:], 100)) {
+		t.Errorf("Reverse on CloneShared leaked to source: %v", got)
+	}
+	assertInvariants(t, cow, 1)
+}
+
+func TestSort(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	cp := New[int](WithChunkSize(16), WithCopyOnPush(false))
+	var want []int
+	var chunks [][]int
+	for i := 0; i < 10; i++ {
+		chunk := make([]int, 1+rng.Intn(40))
+		for j := range chunk {
+			chunk[j] = rng.Intn(50)
+		}
+		want = append(want, chunk...)
+		chunks = append(chunks, chunk)
+		cp.Push(chunk)
+	}
+	backup := slices.Concat(chunks...)
+	cp.PopFront()
+	want = want[1:]
+
+	if IsSorted(cp) {
+		t.Fatal("IsSorted on random data should be false")
+	}
+	Sort(cp)
+	slices.Sort(want)
+	if got := cp.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("Sort = %v, want %v", got, want)
+	}
+	if !IsSorted(cp) || !cp.IsSorted(func(a, b int) bool { return a < b }) {
+		t.Error("IsSorted after Sort should be true")
+	}
+	if got := slices.Concat(chunks...); !slices.Equal(got, backup) {
+		t.Error("Sort should not write into borrowed chunks")
+	}
+	if slices.Max(cp.ChunkLens()) > 16 {
+		t.Errorf("Sort should write chunks of at most the chunk size, got %v", cp.ChunkLens())
+	}
+	assertInvariants(t, cp, 0)
+
+	// 穩定排序：相等的元素保持原本的先後順序
+	type item struct{ key, seq int }
+	ip := New[item](WithChunkSize(8))
+	var items []item
+	for i := 0; i < 100; i++ {
+		it := item{rng.Intn(5), i}
+		items = append(items, it)
+		ip.PushOne(it)
+	}
+	ip.Sort(func(a, b item) bool { return a.key > b.key })
+	slices.SortStableFunc(items, func(a, b item) int { return b.key - a.key })
+	if got := ip.ValueSlice(); !slices.Equal(got, items) {
+		t.Errorf("Sort is not stable: %v", got)
+	}
+
+	// 共用區塊排序時不影響另一方
+	src := New[int]().Push([]int{3, 1, 2})
+	cow := src.CloneShared()
+	Sort(cow)
+	if got := src.ValueSlice(); !slices.Equal(got, []int{3, 1, 2}) {
+		t.Errorf("Sort on CloneShared leaked to source: %v", got)
+	}
+
+	empty := New[int]()
+	Sort(empty)
+	if !IsSorted(empty) || !empty.IsEmpty() {
+		t.Error("Sort on empty pipe should be a no-op")
+	}
+}
+
+func TestBinarySearch(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	var ref []int
+	for i := 0; i < 8; i++ {
+		chunk := make([]int, 10+i)
+		for j := range chunk {
+			chunk[j] = len(ref) / 3 * 2 // 每個值重複三次，且只有偶數
+			ref = append(ref, chunk[j])
+		}
+		cp.Push(chunk)
+	}
+	cp.PopFront()
+	cp.PopEnd()
+	ref = ref[1 : len(ref)-1]
+
+	for target := -2; target <= ref[len(ref)-1]+2; target++ {
+		i, found := cp.BinarySearch(target, cmp.Compare[int])
+		wi, wfound := slices.BinarySearch(ref, target)
+		if i != wi || found != wfound {
+			t.Errorf("BinarySearch(%d) = %d, %v, want %d, %v", target, i, found, wi, wfound)
+		}
+	}
+
+	if i, found := New[int]().BinarySearch(1, cmp.Compare[int]); i != 0 || found {
+		t.Errorf("BinarySearch on empty pipe = %d, %v", i, found)
+	}
+}
+
+func TestIndexOf(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push([]int{5, 6, 7, 8, 9, 10, 11, 12, 13, 14})
+	cp.PopFront()
+	eq := func(a, b int) bool { return a == b }
+
+	tests := []struct {
+		v           int
+		first, last int
+	}{
+		{1, 0, 0},
+		{5, 4, 9},
+		{9, 8, 13},
+		{14, 18, 18},
+		{0, -1, -1},
+		{15, -1, -1},
+	}
+	for _, tt := range tests {
+		if got := cp.IndexOf(tt.v, eq); got != tt.first {
+			t.Errorf("IndexOf(%d) = %d, want %d", tt.v, got, tt.first)
+		}
+		if got := cp.LastIndexOf(tt.v, eq); got != tt.last {
+			t.Errorf("LastIndexOf(%d) = %d, want %d", tt.v, got, tt.last)
+		}
+		if got := cp.Contains(tt.v, eq); got != (tt.first >= 0) {
+			t.Errorf("Contains(%d) = %v", tt.v, got)
+		}
+	}
+
+	// 自訂相等：忽略大小寫
+	sp := New[string]().Push([]string{"Foo", "bar", "FOO"})
+	fold := strings.EqualFold
+	if got := sp.IndexOf("foo", fold); got != 0 {
+		t.Errorf("IndexOf with EqualFold = %d, want 0", got)
+	}
+	if got := sp.LastIndexOf("foo", fold); got != 2 {
+		t.Errorf("LastIndexOf with EqualFold = %d, want 2", got)
+	}
+}
+
+func TestCountAnyEvery(t *testing.T) {
+	cp := New[int](WithChunkSize(4))
+	for i := 0; i < 20; i++ {
+		cp.PushOne(i)
+	}
+	cp.PopFront()
+	even := func(v int) bool { return v%2 == 0 }
+	positive := func(v int) bool { return v > 0 }
+
+	if got := cp.Count(even); got != 9 {
+		t.Errorf("Count(even) = %d, want 9", got)
+	}
+	if !cp.Any(even) || cp.Any(func(v int) bool { return v > 19 }) {
+		t.Error("Any returned the wrong result")
+	}
+	if cp.Every(even) || !cp.Every(positive) {
+		t.Error("Every returned the wrong result")
+	}
+
+	empty := New[int]()
+	if empty.Count(positive) != 0 || empty.Any(positive) || !empty.Every(positive) {
+		t.Error("predicates on empty pipe should be 0, false, true")
+	}
+}
+
+func TestFilterApplyReduce(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	borrowed := []int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19}
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.Push(borrowed)
+	cp.PushCopy([]int{20, 21, 22, 23, 24, 25, 26, 27, 28, 29})
+	cp.Push([]int{31, 33, 35, 37, 39, 41, 43, 45, 47, 49})
+	popped := 0
+	cp.OnPop(func(n int) { popped += n })
+
+	calls := 0
+	removed := cp.Filter(func(v int) bool {
+		calls++
+		return v%2 == 0
+	})
+	if removed != 25 || popped != 25 || calls != 40 {
+		t.Errorf("Filter removed %d (hook %d) with %d calls, want 25, 25, 40", removed, popped, calls)
+	}
+	want := []int{0, 2, 4, 6, 8, 10, 12, 14, 16, 18, 20, 22, 24, 26, 28}
+	if got := cp.ValueSlice(); !slices.Equal(got, want) {
+		t.Errorf("after Filter = %v, want %v", got, want)
+	}
+	if borrowed[1] != 11 {
+		t.Error("Filter should not write into borrowed chunks")
+	}
+	assertInvariants(t, cp, 0)
+
+	cp.Apply(func(v int) int { return v * 10 })
+	if got, _ := cp.Get(7); got != 140 {
+		t.Errorf("Get(7) after Apply = %d, want 140", got)
+	}
+	if sum := Reduce(cp, 0, func(acc, v int) int { return acc + v }); sum != 2100 {
+		t.Errorf("Reduce sum = %d, want 2100", sum)
+	}
+	if s := Reduce(cp, "", func(acc string, v int) string { return acc + strconv.Itoa(v/100) }); s != "000001111122222" {
+		t.Errorf("Reduce to string = %q", s)
+	}
+
+	// 共用區塊原地轉換前先複製
+	src := New[int]().Push([]int{1, 2, 3})
+	src.CloneShared().Apply(func(v int) int { return -v })
+	if got := src.ValueSlice(); !slices.Equal(got, []int{1, 2, 3}) {
+		t.Errorf("Apply on CloneShared leaked to source: %v", got)
+	}
+
+	if cp.Filter(func(int) bool { return false }) != 15 || !cp.IsEmpty() {
+		t.Error("Filter rejecting everything should empty the pipe")
+	}
+	assertInvariants(t, cp, 1)
+}
+
+func TestPartition(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	first := []int{0, 2, 4, 6, 8, 1, 3, 5, 7, 9}
+	cp.Push(first)
+	cp.Push([]int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+	cp.PopFront()
+	popped := 0
+	cp.OnPop(func(n int) { popped += n })
+
+	calls := 0
+	even, odd := cp.Partition(func(v int) bool {
+		calls++
+		return v%2 == 0
+	})
+	if calls != 19 || popped != 19 || !cp.IsEmpty() {
+		t.Errorf("Partition called pred %d times, popped %d, left %d", calls, popped, cp.Len())
+	}
+	if got := even.ValueSlice(); !slices.Equal(got, []int{2, 4, 6, 8, 10, 12, 14, 16, 18}) {
+		t.Errorf("even = %v", got)
+	}
+	if got := odd.ValueSlice(); !slices.Equal(got, []int{1, 3, 5, 7, 9, 11, 13, 15, 17, 19}) {
+		t.Errorf("odd = %v", got)
+	}
+	if &even.list[0].val[0] != &first[1] || &odd.list[0].val[0] != &first[5] {
+		t.Error("Partition should reuse contiguous runs without copying")
+	}
+	if got := even.ChunkLens(); !slices.Equal(got, []int{4, 1, 1, 1, 1, 1}) {
+		t.Errorf("even chunk layout = %v", got)
+	}
+	assertInvariants(t, even, 0)
+	assertInvariants(t, odd, 1)
+
+	// 新管道可以獨立推入與彈出，不會覆寫另一邊
+	even.PushOne(20)
+	odd.PopEnd()
+	if got := odd.ValueSlice(); !slices.Equal(got, []int{1, 3, 5, 7, 9, 11, 13, 15, 17}) {
+		t.Errorf("odd after edits = %v", got)
+	}
+
+	// 原管道仍可繼續使用
+	cp.PushOne(1)
+	if a, b := cp.Partition(func(int) bool { return true }); a.Len() != 1 || b.Len() != 0 {
+		t.Errorf("Partition(all) = %d, %d", a.Len(), b.Len())
+	}
+	assertInvariants(t, cp, 2)
+}
+
+func TestDedup(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	cp.Push([]int{9, 1, 1, 2, 2, 2, 3, 3, 3, 3})
+	cp.Push([]int{3, 3, 4, 5, 5, 6, 7, 7, 7, 7})
+	cp.Push([]int{7, 7, 7, 7, 7, 7, 7, 7, 7, 7})
+	cp.Push([]int{7, 8, 8, 9, 1, 1, 1, 1, 1, 1})
+	cp.PopFront()
+
+	removed := cp.Dedup(func(a, b int) bool { return a == b })
+	want := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 1}
+	if got := cp.ValueSlice(); removed != 29 || !slices.Equal(got, want) {
+		t.Errorf("Dedup removed %d, content %v, want 29, %v", removed, got, want)
+	}
+	if got := cp.ChunkLens(); !slices.Equal(got, []int{3, 4, 3}) {
+		t.Errorf("chunk layout after Dedup = %v", got)
+	}
+	assertInvariants(t, cp, 0)
+
+	if n := cp.Dedup(func(a, b int) bool { return a == b }); n != 0 {
+		t.Errorf("second Dedup removed %d, want 0", n)
+	}
+	if n := New[int]().Dedup(func(a, b int) bool { return true }); n != 0 {
+		t.Errorf("Dedup on empty pipe removed %d", n)
+	}
+}
+
+func TestMinMaxSum(t *testing.T) {
+	cp := New[int](WithChunkSize(4))
+	for _, v := range []int{5, -3, 8, 0, 12, -7, 4, 4, 9, 1} {
+		cp.PushOne(v)
+	}
+	cp.PopFront()
+
+	if v, ok := Min(cp); !ok || v != -7 {
+		t.Errorf("Min = %d, %v, want -7", v, ok)
+	}
+	if v, ok := Max(cp); !ok || v != 12 {
+		t.Errorf("Max = %d, %v, want 12", v, ok)
+	}
+	if got := Sum(cp); got != 28 {
+		t.Errorf("Sum = %d, want 28", got)
+	}
+
+	fp := New[float64]().Push([]float64{1.5, 2.25}).Push([]float64{-0.75})
+	if got := Sum(fp); got != 3 {
+		t.Errorf("Sum of floats = %v, want 3", got)
+	}
+	fp.PushOne(math.NaN())
+	if v, _ := Min(fp); !math.IsNaN(v) {
+		t.Errorf("Min with NaN = %v, want NaN", v)
+	}
+
+	sp := New[string]().Push([]string{"pear", "apple", "fig"})
+	if v, _ := Min(sp); v != "apple" {
+		t.Errorf("Min of strings = %q", v)
+	}
+
+	empty := New[uint8]()
+	if _, ok := Min(empty); ok {
+		t.Error("Min on empty pipe should return false")
+	}
+	if _, ok := Max(empty); ok {
+		t.Error("Max on empty pipe should return false")
+	}
+	if Sum(empty) != 0 {
+		t.Error("Sum on empty pipe should be 0")
+	}
+}
+
+func TestEqualAndHash(t *testing.T) {
+	a := NewByteChunkPipe(WithCopyOnPush(false))
+	a.Push([]byte("hello, "))
+	a.Push([]byte("world"))
+	b := NewByteChunkPipe()
+	b.WriteString("hello, world")
+	b.Repartition(3)
+
+	eq := func(x, y byte) bool { return x == y }
+	if !a.Equal(b.ChunkPipe, eq) || !b.Equal(a.ChunkPipe, eq) {
+		t.Error("pipes with the same content should be Equal regardless of layout")
+	}
+	if a.Equal(nil, eq) {
+		t.Error("Equal(nil) should be false")
+	}
+
+	want := fnv.New64a()
+	want.Write([]byte("hello, world"))
+	if a.Sum64() != want.Sum64() || b.Sum64() != want.Sum64() {
+		t.Errorf("Sum64 = %x, %x, want %x", a.Sum64(), b.Sum64(), want.Sum64())
+	}
+	if got := a.Hash(fnv.New64()); got == a.Sum64() {
+		t.Error("Hash should use the given hash function")
+	}
+
+	b.WriteByte('!')
+	if a.Equal(b.ChunkPipe, eq) || a.Sum64() == b.Sum64() {
+		t.Error("different content should not be Equal or hash the same")
+	}
+	if NewByteChunkPipe().Sum64() != fnv.New64a().Sum64() {
+		t.Error("Sum64 of an empty pipe should be the FNV offset basis")
+	}
+}
+
+func TestStringAndDebugDump(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false))
+	if got := cp.String(); got != "ChunkPipe(len=0, chunks=0) []" {
+		t.Errorf("String() on empty pipe = %q", got)
+	}
+	cp.Push([]int{1, 2, 3})
+	if got := fmt.Sprint(cp); got != "ChunkPipe(len=3, chunks=1) [1 2 3]" {
+		t.Errorf("String() = %q", got)
+	}
+
+	cp.Clear()
+	cp.Push([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	cp.PushCopy([]int{10, 11, 12, 13, 14, 15, 16, 17, 18, 19})
+	cp.Push([]int{20, 21, 22, 23, 24, 25, 26, 27, 28, 29})
+	cp.PopFront()
+	cp.CloneShared()
+	cp.PushOne(30)
+	if got := cp.String(); got != "ChunkPipe(len=30, chunks=4) [1 2 3 4 5 6 7 8 ...]" {
+		t.Errorf("String() with preview = %q", got)
+	}
+
+	var buf bytes.Buffer
+	if err := cp.DebugDump(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 5 || !strings.Contains(lines[0], "len=30 offset=4 chunks=4") {
+		t.Fatalf("DebugDump =\n%s", buf.String())
+	}
+	for i, want := range []string{"#0 [4, 13) len=9", "#1 [13, 23) len=10", "#2 [23, 33) len=10", "#3 [33, 34) len=1"} {
+		if !strings.Contains(lines[i+1], want) {
+			t.Errorf("DebugDump line %d = %q, want %q", i+1, lines[i+1], want)
+		}
+	}
+	if !strings.HasSuffix(lines[1], "shared") || !strings.HasSuffix(lines[4], "owned") {
+		t.Errorf("DebugDump should report chunk ownership:\n%s", buf.String())
+	}
+}
+
+func TestCheckInvariants(t *testing.T) {
+	cp := New[int](WithChunkSize(4))
+	for i := 0; i < 10; i++ {
+		cp.PushOne(i)
+	}
+	if err := cp.CheckInvariants(); err != nil {
+		t.Fatalf("CheckInvariants on a valid pipe = %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		corrupt func(cp *ChunkPipe[int])
+	}{
+		{"empty chunk", func(cp *ChunkPipe[int]) { cp.list[1].val = cp.list[1].val[:0] }},
+		{"gap", func(cp *ChunkPipe[int]) { cp.list[1].off++ }},
+		{"offset", func(cp *ChunkPipe[int]) { cp.offset-- }},
+		{"cap", func(cp *ChunkPipe[int]) { cp.list[0].cap++ }},
+		{"shared owned", func(cp *ChunkPipe[int]) { cp.list[0].shared = true }},
+		{"dead", func(cp *ChunkPipe[int]) { cp.list[0].dead = -1 }},
+	}
+	for _, tt := range tests {
+		c := cp.Clone()
+		c.Repartition(4)
+		tt.corrupt(c)
+		if err := c.CheckInvariants(); err == nil {
+			t.Errorf("CheckInvariants did not detect %s", tt.name)
+		}
+	}
+}
+
+// countingCollector 以原子操作累計收到的指標
+type countingCollector struct {
+	pushed, popped, allocs atomic.Int64
+	blocked                atomic.Int64
+	waits                  atomic.Int64
+}
+
+func (c *countingCollector) Pushed(n int)          { c.pushed.Add(int64(n)) }
+func (c *countingCollector) Popped(n int)          { c.popped.Add(int64(n)) }
+func (c *countingCollector) ChunksAllocated(n int) { c.allocs.Add(int64(n)) }
+func (c *countingCollector) Blocked(d time.Duration) {
+	c.blocked.Add(int64(d))
+	c.waits.Add(1)
+}
+
+func TestWithMetrics(t *testing.T) {
+	var c countingCollector
+	cp := New[int](WithMetrics(&c), WithChunkSize(4), WithMaxLen(10))
+	for i := 0; i < 10; i++ {
+		cp.PushOne(i)
+	}
+	cp.PopFront()
+	cp.Discard(2)
+	if c.pushed.Load() != 10 || c.popped.Load() != 3 || c.allocs.Load() != 3 {
+		t.Errorf("pushed %d, popped %d, allocs %d, want 10, 3, 3", c.pushed.Load(), c.popped.Load(), c.allocs.Load())
+	}
+
+	// 衍生的管道沿用同一個收集器，移動也計入推入與移除
+	_, right := cp.Split(3)
+	if c.popped.Load() != 7 {
+		t.Errorf("popped after Split = %d, want 7", c.popped.Load())
+	}
+	cp.Append(right)
+	if c.pushed.Load() != 14 || c.popped.Load() != 11 {
+		t.Errorf("after Append: pushed %d, popped %d, want 14, 11", c.pushed.Load(), c.popped.Load())
+	}
+
+	// 阻塞的推入與彈出回報等待時間
+	cp.Push(make([]int, 3))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cp.Push([]int{1}) // 已滿，等待空間
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cp.PopFront()
+	<-done
+
+	empty := New[int](WithMetrics(&c))
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		empty.PushOne(1)
+	}()
+	if _, err := empty.PopFrontCtx(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if c.waits.Load() != 2 || time.Duration(c.blocked.Load()) < 30*time.Millisecond {
+		t.Errorf("Blocked called %d times for %v, want 2 waits of about 20ms", c.waits.Load(), time.Duration(c.blocked.Load()))
+	}
+}
+
+func TestWithTraceHook(t *testing.T) {
+	var events []string
+	cp := New[int](WithTraceHook(func(op Op, n int) {
+		events = append(events, fmt.Sprintf("%v %d", op, n))
+	}))
+	cp.Push([]int{1, 2, 3})
+	cp.PushFront([]int{0})
+	cp.PopFront()
+	cp.Discard(2)
+	cp.Push(nil)
+	_, right := cp.Split(0)
+	cp.Append(right)
+	cp.Clear()
+	cp.Clear()
+
+	want := []string{"push 3", "push 1", "pop 1", "pop 2", "pop 1", "push 1", "pop 1", "clear 1"}
+	if !slices.Equal(events, want) {
+		t.Errorf("trace events = %v, want %v", events, want)
+	}
+	if s := Op(9).String(); s != "op(9)" {
+		t.Errorf("unknown Op String = %q", s)
+	}
+
+	// 事件在寫鎖內依修改順序回報，並發推入與彈出的事件總數必須吻合
+	var pushed, popped atomic.Int64
+	cp = New[int](WithTraceHook(func(op Op, n int) {
+		if op == OpPush {
+			pushed.Add(int64(n))
+		} else {
+			popped.Add(int64(n))
+		}
+	}))
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				cp.PushOne(i)
+				cp.PopFront()
+			}
+		}()
+	}
+	wg.Wait()
+	if pushed.Load() != 4000 || popped.Load() != 4000-int64(cp.Len()) {
+		t.Errorf("traced %d pushes and %d pops, %d left", pushed.Load(), popped.Load(), cp.Len())
+	}
+}
+
+func TestRetiredChunksReused(t *testing.T) {
+	cp := New[*int](WithChunkSize(16))
+	v := new(int)
+	for round := 0; round < 50; round++ {
+		for i := 0; i < 32; i++ {
+			cp.PushOne(v)
+		}
+		for i := 0; i < 32; i++ {
+			if got, ok := cp.PopFront(); !ok || got != v {
+				t.Fatalf("round %d: PopFront = %v, %v", round, got, ok)
+			}
+		}
+	}
+	// 池的內容不保證保留，只要求大部分區塊來自取空後放回的區塊
+	if s := cp.Stats(); s.ChunksReused < s.ChunksAllocated {
+		t.Errorf("reused %d chunks, allocated %d", s.ChunksReused, s.ChunksAllocated)
+	}
+
+	// 放回池中的區塊不再引用已彈出的元素，從尾端取空的區塊同樣放回
+	cp.PushAll(make([]*int, 16), make([]*int, 16))
+	cp.Discard(16)
+	cp.PopEnd()
+	cp.Discard(15)
+	for i := 0; i < 16; i++ {
+		cp.PushOne(nil)
+	}
+	for _, c := range cp.list {
+		if slices.ContainsFunc(c.val[:cap(c.val)], func(p *int) bool { return p != nil }) {
+			t.Error("reused chunk still references popped elements")
+		}
+	}
+	if err := cp.CheckInvariants(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestChunksSurviveGC(t *testing.T) {
+	// 借用的切片由管道以 []T 引用，原本的變數離開作用域後仍不會被回收
+	cp := New[*int](WithCopyOnPush(false))
+	for i := 0; i < 100; i++ {
+		chunk := make([]*int, 16)
+		for j := range chunk {
+			v := i*16 + j
+			chunk[j] = &v
+		}
+		cp.Push(chunk)
+	}
+	for i := 0; i < 3; i++ {
+		runtime.GC()
+	}
+	i := 0
+	for v := range cp.Values() {
+		if *v != i {
+			t.Fatalf("element %d = %d after GC", i, *v)
+		}
+		i++
+	}
+
+	// memoryPool 保留的區塊對垃圾回收器可見，重新分配時不會懸空
+	p := newMemoryPool()
+	ptr := p.Alloc(64)
+	copy(unsafe.Slice((*byte)(ptr), 64), "hello")
+	p.Free(ptr, 64)
+	if p.Size() != 64 {
+		t.Errorf("Size after Free = %d, want 64", p.Size())
+	}
+	runtime.GC()
+	for i := 0; i < 1000; i++ {
+		_ = make([]byte, 64) // 若區塊已被回收，這些配置可能重複使用同一塊記憶體
+	}
+	got := unsafe.Slice((*byte)(p.Alloc(64)), 64)
+	if !slices.Equal(got, make([]byte, 64)) || p.Size() != 0 {
+		t.Errorf("reused block = %v, size %d, want a zeroed block", got[:8], p.Size())
+	}
+	p.Reset()
+}
+
+func TestOffHeap(t *testing.T) {
+	if New[*int](WithOffHeap()).allocator != nil || New[struct{}](WithOffHeap()).allocator != nil {
+		t.Error("WithOffHeap should be ignored for pointer and zero-size element types")
+	}
+
+	cp := New[int64](WithOffHeap(), WithChunkSize(64))
+	if cp.allocator == nil {
+		t.Fatal("WithOffHeap should apply to int64")
+	}
+	for i := int64(0); i < 1000; i++ {
+		cp.PushOne(i)
+	}
+	if runtime.GOOS == "linux" && len(cp.external) == 0 {
+		t.Fatal("chunks should be mapped off the Go heap")
+	}
+
+	// 整塊交出的區塊是堆積上的副本，Clear 解除映射後仍可使用
+	chunk, _ := cp.PopChunkFront()
+	tail, _ := cp.PopChunkEnd()
+	cp.Clear()
+	if len(cp.external) != 0 || len(cp.free) != 0 {
+		t.Errorf("Clear left %d mapped chunks, %d free", len(cp.external), len(cp.free))
+	}
+	if chunk[0] != 0 || chunk[63] != 63 || tail[len(tail)-1] != 999 {
+		t.Errorf("chunks popped before Clear = %d..%d, %d", chunk[0], chunk[63], tail[len(tail)-1])
+	}
+
+	// 隨機操作與參考切片比較，涵蓋切開、移動與重排映射區塊的路徑
+	rng := rand.New(rand.NewSource(3))
+	var ref []int64
+	next := int64(0)
+	for step := 0; step < 3000; step++ {
+		switch rng.Intn(10) {
+		case 0, 1, 2:
+			data := make([]int64, rng.Intn(100))
+			for i := range data {
+				data[i] = next
+				next++
+			}
+			cp.PushCopy(data)
+			ref = append(ref, data...)
+		case 3:
+			n := rng.Intn(80)
+			got, _ := cp.PopFrontN(min(n, len(ref)))
+			if !slices.Equal(got, ref[:len(got)]) {
+				t.Fatalf("step %d: PopFrontN = %v", step, got)
+			}
+			ref = ref[len(got):]
+		case 4:
+			if got, ok := cp.PopChunkEnd(); ok {
+				if !slices.Equal(got, ref[len(ref)-len(got):]) {
+					t.Fatalf("step %d: PopChunkEnd = %v", step, got)
+				}
+				ref = ref[:len(ref)-len(got)]
+			}
+		case 5:
+			index := rng.Intn(len(ref) + 1)
+			cp.Insert(index, []int64{-1, -2})
+			ref = slices.Insert(ref, index, -1, -2)
+		case 6:
+			if len(ref) > 0 {
+				index := rng.Intn(len(ref))
+				cp.RemoveAt(index)
+				ref = slices.Delete(ref, index, index+1)
+			}
+		case 7:
+			_, right := cp.Split(rng.Intn(len(ref) + 1))
+			cp.Append(right)
+		case 8:
+			if rng.Intn(4) == 0 {
+				Sort(cp)
+				slices.Sort(ref)
+			} else {
+				cp.Repartition(1 + rng.Intn(100))
+			}
+		case 9:
+			if rng.Intn(10) == 0 {
+				cp.Clear()
+				ref = nil
+			}
+		}
+		if got := cp.ValueSlice(); !slices.Equal(got, ref) {
+			t.Fatalf("step %d: content differs from reference", step)
+		}
+		if err := cp.CheckInvariants(); err != nil {
+			t.Fatalf("step %d: %v", step, err)
+		}
+	}
+
+	cp.Drain(func(int64) {})
+	cp.Close()
+	if len(cp.external) != 0 {
+		t.Errorf("%d chunks still mapped after Drain and Close", len(cp.external))
+	}
+}
+
+// testAllocator 從 Go 的堆積配置並記錄尚未歸還的記憶體，offset 不為 0 時故意返回未對齊的位址
+type testAllocator struct {
+	live   map[unsafe.Pointer][]uint64
+	allocs int
+	frees  int
+	offset uintptr
+}
+
+func (a *testAllocator) Alloc(nBytes int) unsafe.Pointer {
+	a.allocs++
+	buf := make([]uint64, (nBytes+7)/8+1)
+	p := unsafe.Add(unsafe.Pointer(&buf[0]), a.offset)
+	a.live[p] = buf
+	return p
+}
+
+func (a *testAllocator) Free(ptr unsafe.Pointer, nBytes int) {
+	if _, ok := a.live[ptr]; !ok {
+		panic("free of unknown pointer")
+	}
+	a.frees++
+	delete(a.live, ptr)
+}
+
+func TestWithAllocator(t *testing.T) {
+	a := &testAllocator{live: map[unsafe.Pointer][]uint64{}}
+	cp := New[int32](WithAllocator(a), WithChunkSize(16))
+	for i := int32(0); i < 100; i++ {
+		cp.PushOne(i)
+	}
+	if a.allocs == 0 || len(cp.external) != len(a.live) {
+		t.Fatalf("allocs = %d, external = %d, live = %d", a.allocs, len(cp.external), len(a.live))
+	}
+	_, right := cp.Split(50)
+	if got := right.ValueSlice(); len(got) != 50 || got[0] != 50 {
+		t.Fatalf("right half = %v", got)
+	}
+	right.Clear()
+	if got := cp.PopAll(); len(got) != 50 || got[49] != 49 {
+		t.Fatalf("PopAll = %v", got)
+	}
+	cp.Close()
+	if len(a.live) != 0 || a.frees != a.allocs {
+		t.Errorf("allocs = %d, frees = %d, %d still live", a.allocs, a.frees, len(a.live))
+	}
+
+	// 未對齊的位址立即歸還並改用堆積配置
+	bad := &testAllocator{live: map[unsafe.Pointer][]uint64{}, offset: 1}
+	cp = New[int32](WithAllocator(bad))
+	cp.Push([]int32{1, 2, 3})
+	if len(cp.external) != 0 || bad.allocs != 1 || bad.frees != 1 {
+		t.Errorf("misaligned memory should be freed, allocs = %d, frees = %d", bad.allocs, bad.frees)
+	}
+	if got := cp.ValueSlice(); !slices.Equal(got, []int32{1, 2, 3}) {
+		t.Errorf("ValueSlice = %v", got)
+	}
+}
+
+func TestCompact(t *testing.T) {
+	cp := New[int](WithCopyOnPush(false), WithChunkSize(16))
+	var ref []int
+	for i := 0; i < 100; i++ {
+		cp.Push([]int{i})
+		ref = append(ref, i)
+	}
+	cp.Push(make([]int, 40))
+	ref = append(ref, make([]int, 40)...)
+	cp.PopFrontN(3)
+	ref = ref[3:]
+	cp.RemoveAt(50)
+	ref = slices.Delete(ref, 50, 51)
+
+	cp.Compact()
+	assertInvariants(t, cp, 0)
+	if got := cp.ValueSlice(); !slices.Equal(got, ref) {
+		t.Fatalf("Compact changed content: %v", got)
+	}
+	// 97 個單元素區塊合併為 7 塊，40 個元素的借用區塊保持不動
+	if chunks := cp.Stats().Chunks; chunks != 8 {
+		t.Errorf("Chunks = %d, want 8", chunks)
+	}
+	cp.Compact()
+	if chunks := cp.Stats().Chunks; chunks != 8 {
+		t.Errorf("second Compact: Chunks = %d, want 8", chunks)
+	}
+
+	auto := New[int](WithCopyOnPush(false), WithChunkSize(16), WithAutoCompact(0.25))
+	for i := 0; i < 1000; i++ {
+		auto.Push([]int{i})
+		if chunks := auto.Stats().Chunks; chunks > 2*minCompactChunks+i/4 {
+			t.Fatalf("after %d pushes: %d chunks", i+1, chunks)
+		}
+	}
+	assertInvariants(t, auto, 1)
+	if s := auto.Stats(); s.Chunks > 1000/16+minCompactChunks || s.Elements != 1000 {
+		t.Errorf("auto compaction left %d chunks for %d elements", s.Chunks, s.Elements)
+	}
+	if got := auto.ValueSlice(); got[0] != 0 || got[999] != 999 {
+		t.Errorf("content = %d..%d", got[0], got[999])
+	}
+}
+
+func TestTrimFrontShrink(t *testing.T) {
+	data := make([]int, 1000)
+	for i := range data {
+		data[i] = i
+	}
+	// 比例為 0 時 PopFront 不自動重新配置，舊陣列一直保留到 TrimFront
+	cp := New[int](WithTrimRatio(0))
+	cp.PushOwned(data)
+	cp.PopFrontN(900)
+	if head := cp.list[0]; head.dead != 900 {
+		t.Fatalf("dead = %d, want 900 without automatic trimming", head.dead)
+	}
+	if n := cp.TrimFront(); n != 900 {
+		t.Errorf("TrimFront = %d, want 900", n)
+	}
+	if head := cp.list[0]; head.dead != 0 || cap(head.val) >= len(data) {
+		t.Errorf("head after TrimFront: dead = %d, cap = %d", head.dead, cap(head.val))
+	}
+	if n := cp.TrimFront(); n != 0 {
+		t.Errorf("second TrimFront = %d, want 0", n)
+	}
+	assertInvariants(t, cp, 0)
+	if v, _ := cp.PeekFront(); v != 900 {
+		t.Errorf("PeekFront = %d, want 900", v)
+	}
+
+	// 前綴未達比例時 TrimFront 不做任何事
+	cp = New[int](WithTrimRatio(10))
+	cp.PushOwned(slices.Clone(data))
+	cp.PopFrontN(500)
+	if n := cp.TrimFront(); n != 0 || cp.list[0].dead != 500 {
+		t.Errorf("TrimFront below ratio = %d, dead = %d", n, cp.list[0].dead)
+	}
+
+	cp = New[int](WithChunkSize(16), WithInitialCapacity(64))
+	cp.PushOne(1)
+	for i := 0; i < 64; i++ {
+		cp.PushOne(i)
+	}
+	cp.PopFrontN(40)
+	cp.Discard(cp.Len())
+	cp.PushCopy([]int{1, 2, 3})
+	cp.PopFront()
+	if n := cp.Shrink(); n == 0 || len(cp.free) != 0 || cp.spare != nil {
+		t.Errorf("Shrink = %d, %d free chunks left", n, len(cp.free))
+	}
+	if cp.list[0].dead != 0 {
+		t.Errorf("head dead = %d after Shrink", cp.list[0].dead)
+	}
+	if got := cp.ValueSlice(); !slices.Equal(got, []int{2, 3}) {
+		t.Errorf("ValueSlice = %v", got)
+	}
+}
+
+func TestDropFrontEnd(t *testing.T) {
+	cp := New[int](WithChunkSize(8))
+	var ref []int
+	for i := 0; i < 100; i++ {
+		cp.PushOne(i)
+		ref = append(ref, i)
+	}
+
+	if n := cp.DropFront(13); n != 13 {
+		t.Errorf("DropFront = %d, want 13", n)
+	}
+	if n := cp.DropEnd(21); n != 21 {
+		t.Errorf("DropEnd = %d, want 21", n)
+	}
+	ref = ref[13 : len(ref)-21]
+	assertInvariants(t, cp, 0)
+	if got := cp.ValueSlice(); !slices.Equal(got, ref) {
+		t.Fatalf("ValueSlice = %v", got)
+	}
+	if n := cp.DropFront(-1) + cp.DropEnd(0); n != 0 {
+		t.Errorf("dropping nothing removed %d elements", n)
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		cp.DropFront(1)
+		cp.DropEnd(1)
+	})
+	if allocs != 0 {
+		t.Errorf("DropFront/DropEnd allocated %.1f times per run", allocs)
+	}
+
+	if left, n := cp.Len(), cp.DropEnd(1000); n != left || cp.Len() != 0 {
+		t.Errorf("DropEnd past the start = %d, Len = %d", n, cp.Len())
+	}
+	assertInvariants(t, cp, 1)
+}
+
+func TestChunkNormalization(t *testing.T) {
+	for _, copyOnPush := range []bool{true, false} {
+		cp := New[int](WithChunkSize(16), WithChunkNormalization(true), WithCopyOnPush(copyOnPush))
+		var ref []int
+		push := func(n int) {
+			data := make([]int, n)
+			for i := range data {
+				data[i] = len(ref) + i
+			}
+			cp.Push(data)
+			ref = append(ref, data...)
+		}
+		push(100)
+		for i := 0; i < 20; i++ {
+			push(3)
+		}
+		push(40)
+		cp.PushOwned(slices.Clone(ref[:50]))
+		ref = append(ref, ref[:50]...)
+		cp.PushOne(-1)
+		ref = append(ref, -1)
+
+		assertInvariants(t, cp, 0)
+		if got := cp.ValueSlice(); !slices.Equal(got, ref) {
+			t.Fatalf("copyOnPush=%v: content differs from reference", copyOnPush)
+		}
+		for i, chunk := range cp.ChunkSlice() {
+			if len(chunk) > 16 {
+				t.Errorf("copyOnPush=%v: chunk %d has %d elements", copyOnPush, i, len(chunk))
+			}
+		}
+		// 60 個三元素推入合併進同一批區塊，而不是各自成為一塊
+		if s := cp.Stats(); s.Chunks > len(ref)/16+4 {
+			t.Errorf("copyOnPush=%v: %d chunks for %d elements", copyOnPush, s.Chunks, len(ref))
+		}
+
+		for len(ref) > 0 {
+			chunk, _ := cp.PopChunkFront()
+			if !slices.Equal(chunk, ref[:len(chunk)]) {
+				t.Fatalf("copyOnPush=%v: PopChunkFront = %v", copyOnPush, chunk)
+			}
+			ref = ref[len(chunk):]
+		}
+	}
+
+	// 未開啟時大推入仍是單一區塊
+	cp := New[int](WithChunkSize(16))
+	cp.Push(make([]int, 100))
+	if s := cp.Stats(); s.Chunks != 1 {
+		t.Errorf("without normalization: %d chunks, want 1", s.Chunks)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	data := []int{1, 2, 3, 4, 5, 6, 7, 8}
+	for _, normalize := range []bool{false, true} {
+		cp := New[int](WithChunkSize(16), WithChunkNormalization(normalize))
+		cp.PushOne(0)
+		// AllocsPerRun 會先多執行一次暖身，因此預留兩輪的容量
+		cp.Reserve(2 * 100 * len(data))
+		allocs := testing.AllocsPerRun(1, func() {
+			for i := 0; i < 100; i++ {
+				cp.Push(data)
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("normalize=%v: %.0f allocations after Reserve", normalize, allocs)
+		}
+		if cp.Len() != 1+2*100*len(data) {
+			t.Errorf("normalize=%v: Len = %d", normalize, cp.Len())
+		}
+		assertInvariants(t, cp, 0)
+	}
+
+	cp := New[int]()
+	cp.Reserve(0)
+	cp.Reserve(-5)
+	if len(cp.free) != 0 {
+		t.Errorf("Reserve of nothing allocated %d chunks", len(cp.free))
+	}
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	ring := NewRing[int](5, WithChunkSize(2))
+	for i := 0; i < 12; i++ {
+		ring.PushOne(i)
+	}
+	if got := ring.ValueSlice(); !slices.Equal(got, []int{7, 8, 9, 10, 11}) {
+		t.Errorf("ring after 12 pushes = %v", got)
+	}
+	ring.Push([]int{20, 21, 22, 23, 24, 25, 26})
+	if got := ring.ValueSlice(); !slices.Equal(got, []int{22, 23, 24, 25, 26}) {
+		t.Errorf("ring after oversized push = %v", got)
+	}
+	if err := ring.TryPush([]int{30}); err != nil {
+		t.Errorf("TryPush on ring = %v", err)
+	}
+	ring.PushFront([]int{-2, -1})
+	if got := ring.ValueSlice(); !slices.Equal(got, []int{-2, -1, 23, 24, 25}) {
+		t.Errorf("ring after PushFront = %v", got)
+	}
+	assertInvariants(t, ring, 0)
+
+	newest := NewBounded[int](4, WithOverflow(OverflowDropNewest))
+	newest.Push([]int{1, 2, 3})
+	newest.Push([]int{4, 5, 6})
+	newest.PushOne(7)
+	if got := newest.ValueSlice(); !slices.Equal(got, []int{1, 2, 3, 4}) {
+		t.Errorf("DropNewest = %v", got)
+	}
+
+	b := NewByteChunkPipe(WithMaxLen(4), WithOverflow(OverflowError))
+	if n, err := b.Write([]byte("abc")); n != 3 || err != nil {
+		t.Errorf("Write = %d, %v", n, err)
+	}
+	if n, err := b.Write([]byte("de")); n != 0 || !errors.Is(err, ErrFull) {
+		t.Errorf("Write over the limit = %d, %v, want 0, ErrFull", n, err)
+	}
+	b.Push([]byte("xy"))
+	if got := string(b.ValueSlice()); got != "abc" {
+		t.Errorf("OverflowError content = %q", got)
+	}
+
+	// 滿載時推入不阻塞，移除的元素計入 OnPop
+	popped := 0
+	ring = NewRing[int](3)
+	ring.OnPop(func(n int) { popped += n })
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			ring.PushOne(i)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("push on a full ring blocked")
+	}
+	if popped != 7 || ring.Len() != 3 {
+		t.Errorf("popped = %d, Len = %d", popped, ring.Len())
+	}
+}
+
+func TestOnEvict(t *testing.T) {
+	var evicted [][]int
+	record := func(batch []int) { evicted = append(evicted, batch) }
+
+	ring := NewRing[int](4, WithChunkSize(2)).OnEvict(record)
+	ring.Push([]int{1, 2, 3})
+	ring.Push([]int{4, 5})
+	ring.Push([]int{6, 7, 8, 9, 10, 11})
+	ring.PushFront([]int{-1})
+	want := [][]int{{1}, {2, 3, 4, 5, 6, 7}, {11}}
+	if !slices.EqualFunc(evicted, want, slices.Equal[[]int]) {
+		t.Errorf("ring evicted %v, want %v", evicted, want)
+	}
+	if got := ring.ValueSlice(); !slices.Equal(got, []int{-1, 8, 9, 10}) {
+		t.Errorf("ring = %v", got)
+	}
+
+	evicted = nil
+	ring.Clear()
+	if !slices.EqualFunc(evicted, [][]int{{-1, 8, 9, 10}}, slices.Equal[[]int]) {
+		t.Errorf("Clear evicted %v", evicted)
+	}
+
+	evicted = nil
+	newest := NewBounded[int](2, WithOverflow(OverflowDropNewest)).OnEvict(record)
+	newest.Push([]int{1, 2, 3})
+	errPipe := NewBounded[int](2, WithOverflow(OverflowError)).OnEvict(record)
+	errPipe.Push([]int{4, 5, 6})
+	if !slices.EqualFunc(evicted, [][]int{{3}, {4, 5, 6}}, slices.Equal[[]int]) {
+		t.Errorf("DropNewest and Error evicted %v", evicted)
+	}
+
+	// 一般的彈出與 Discard 不算丟棄；取消註冊後不再呼叫
+	evicted = nil
+	newest.PopFront()
+	newest.Discard(1)
+	newest.OnEvict(nil)
+	newest.Push([]int{7, 8, 9})
+	newest.Clear()
+	if evicted != nil {
+		t.Errorf("unexpected evictions %v", evicted)
+	}
+}
+
+func TestPopOlderThan(t *testing.T) {
+	cp := New[int](WithTimestamps(), WithChunkSize(4))
+	if _, ok := cp.OldestAge(); ok {
+		t.Error("OldestAge on an empty pipe should report false")
+	}
+	cp.Push([]int{1, 2, 3})
+	cp.PushOne(4)
+	time.Sleep(40 * time.Millisecond)
+	cp.Push([]int{5, 6})
+	cp.PushFront([]int{0})
+
+	if age, ok := cp.OldestAge(); !ok || age < 40*time.Millisecond {
+		t.Errorf("OldestAge = %v, %v", age, ok)
+	}
+	if got := cp.PopOlderThan(20 * time.Millisecond); !slices.Equal(got, []int{0, 1, 2, 3, 4}) {
+		t.Errorf("PopOlderThan = %v, want [0 1 2 3 4]", got)
+	}
+	if got := cp.PopOlderThan(time.Hour); len(got) != 0 {
+		t.Errorf("nothing is an hour old, got %v", got)
+	}
+
+	// 尾端被移除後重新推入的元素以新的時間計算
+	cp.DropEnd(1)
+	time.Sleep(40 * time.Millisecond)
+	cp.PushOne(7)
+	if got := cp.PopOlderThan(20 * time.Millisecond); !slices.Equal(got, []int{5}) {
+		t.Errorf("PopOlderThan after DropEnd = %v, want [5]", got)
+	}
+	assertInvariants(t, cp, 0)
+
+	if got := New[int]().PushOne(1).PopOlderThan(0); len(got) != 0 {
+		t.Errorf("pipe without timestamps popped %v", got)
+	}
+}
+
+func TestTTLJanitor(t *testing.T) {
+	evicted := make(chan []int, 10)
+	cp := New[int](WithTTL(20 * time.Millisecond))
+	cp.OnEvict(func(batch []int) { evicted <- batch })
+	defer cp.Close()
+
+	cp.Push([]int{1, 2, 3})
+	select {
+	case batch := <-evicted:
+		if !slices.Equal(batch, []int{1, 2, 3}) {
+			t.Errorf("janitor evicted %v", batch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("janitor did not evict expired elements")
+	}
+	if cp.Len() != 0 {
+		t.Errorf("Len = %d after expiry", cp.Len())
+	}
+	if got := cp.PopExpired(); len(got) != 0 {
+		t.Errorf("PopExpired = %v", got)
+	}
+
+	// 衍生的管道不記錄時間，不會留下永遠不過期卻宣稱有記錄時間的元素
+	timed := New[int](WithTTL(time.Hour))
+	defer timed.Close()
+	timed.Push([]int{4, 5, 6, 7})
+	_, right := timed.Split(2)
+	for _, p := range []*ChunkPipe[int]{timed.Clone(), right} {
+		if p.stamped || p.ttl != 0 {
+			t.Errorf("derived pipe stamped = %v, ttl = %v, want untimed", p.stamped, p.ttl)
+		}
+		if _, ok := p.OldestAge(); ok {
+			t.Error("OldestAge on a derived pipe should report no timestamps")
+		}
+	}
+}
+
+func TestPopTimeout(t *testing.T) {
+	cp := NewChunkPipe[int]()
+
+	start := time.Now()
+	if _, ok := cp.PopFrontTimeout(20 * time.Millisecond); ok {
+		t.Error("PopFrontTimeout on an empty pipe should time out")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("PopFrontTimeout returned after %v", elapsed)
+	}
+	if _, ok := cp.PopEndTimeout(0); ok {
+		t.Error("PopEndTimeout(0) on an empty pipe should not wait or succeed")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cp.Push([]int{1, 2})
+	}()
+	if v, ok := cp.PopFrontTimeout(time.Second); !ok || v != 1 {
+		t.Errorf("PopFrontTimeout = %v, %v, want 1, true", v, ok)
+	}
+	if v, ok := cp.PopEndTimeout(time.Second); !ok || v != 2 {
+		t.Errorf("PopEndTimeout = %v, %v, want 2, true", v, ok)
+	}
+
+	cp.Close()
+	start = time.Now()
+	if _, ok := cp.PopFrontTimeout(time.Second); ok || time.Since(start) > 500*time.Millisecond {
+		t.Error("PopFrontTimeout on a closed, empty pipe should return false immediately")
+	}
+}
+
+// hookWriter 在第一次寫入前呼叫 before，模擬其他 goroutine 在 WriteTo 寫出期間操作管道
+type hookWriter struct {
+	bytes.Buffer
+	before func()
+}
+
+func (w *hookWriter) Write(p []byte) (int, error) {
+	if w.before != nil {
+		w.before()
+		w.before = nil
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestViewsSurviveConcurrentPopPush(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithOffHeap()}} {
+		b := NewByteChunkPipe(append(opts, WithChunkSize(4))...)
+		b.WriteString("abcd")
+		b.WriteString("efgh")
+		w := &hookWriter{before: func() {
+			b.PopFrontN(4)
+			b.WriteString("XXXX")
+			b.Close()
+		}}
+		if _, err := b.WriteTo(w); err != nil {
+			t.Fatal(err)
+		}
+		if got := w.String(); got != "abcdefghXXXX" {
+			t.Errorf("opts=%d: WriteTo wrote %q, want %q", len(opts), got, "abcdefghXXXX")
+		}
+
+		// Chunks 走訪中的視圖同樣不會被之後的推入覆寫
+		cp := New[int](append(opts, WithChunkSize(4))...)
+		cp.Push([]int{1, 2, 3, 4})
+		cp.Push([]int{5, 6, 7, 8})
+		var got []int
+		first := true
+		for chunk := range cp.Chunks() {
+			if first {
+				first = false
+				cp.PopFrontN(4)
+				cp.Push([]int{-1, -1, -1, -1})
+			}
+			got = append(got, chunk...)
+		}
+		if want := []int{1, 2, 3, 4, 5, 6, 7, 8, -1, -1, -1, -1}; !slices.Equal(got, want) {
+			t.Errorf("opts=%d: Chunks saw %v, want %v", len(opts), got, want)
+		}
+		if cp.pinned != 0 || len(cp.parked) != 0 {
+			t.Errorf("opts=%d: %d views still pinned, %d arrays parked", len(opts), cp.pinned, len(cp.parked))
+		}
+		assertInvariants(t, cp, 0)
+	}
+}
+
+func TestWriteToConcurrentModification(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(b *ByteChunkPipe)
+		want   string
+	}{
+		{"PushFront", func(b *ByteChunkPipe) { b.PushFront([]byte("ZZ")) }, "abcdZZefgh"},
+		{"Insert", func(b *ByteChunkPipe) { b.Insert(2, []byte("--")) }, "abcd--efgh"},
+		{"PopFront", func(b *ByteChunkPipe) { b.PopFrontN(2) }, "abcdefgh"},
+		{"Clear", func(b *ByteChunkPipe) { b.Clear(); b.WriteString("new") }, "abcdnew"},
+	}
+	for _, tt := range tests {
+		b := NewByteChunkPipe(WithChunkSize(4))
+		b.WriteString("abcd")
+		b.WriteString("efgh")
+		w := &hookWriter{before: func() {
+			tt.modify(b)
+			b.Close()
+		}}
+		if _, err := b.WriteTo(w); err != nil {
+			t.Fatal(err)
+		}
+		// 寫出期間的修改只影響尚未寫出的部分，已寫出的位元組不會重複寫出，其他位元組也不會被誤刪
+		if got := w.String(); got != tt.want {
+			t.Errorf("%s: WriteTo wrote %q, want %q", tt.name, got, tt.want)
+		}
+		if b.Len() != 0 {
+			t.Errorf("%s: %d bytes left after WriteTo", tt.name, b.Len())
+		}
+		if err := b.checkInvariants(); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestAllocatorFreedAfterClose(t *testing.T) {
+	for _, drain := range []bool{false, true} {
+		a := &testAllocator{live: map[unsafe.Pointer][]uint64{}}
+		cp := New[int32](WithAllocator(a), WithChunkSize(4))
+		for i := int32(0); i < 20; i++ {
+			cp.PushOne(i)
+		}
+		cp.Close()
+		if drain {
+			cp.Drain(func(int32) {})
+		} else {
+			for {
+				if _, ok := cp.PopFront(); !ok {
+					break
+				}
+			}
+		}
+		if len(a.live) != 0 || a.frees != a.allocs {
+			t.Errorf("drain=%v: allocs = %d, frees = %d, %d still live", drain, a.allocs, a.frees, len(a.live))
+		}
+	}
+}
+
+func TestTimestampsFollowRemovals(t *testing.T) {
+	cp := New[int](WithTimestamps())
+	for i := 0; i < 100; i++ {
+		cp.PushOne(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	for i := 100; i < 200; i++ {
+		cp.PushOne(i)
+	}
+
+	// 從舊的一批中間移除元素後，新的元素不能繼承舊的時間
+	cp.Filter(func(v int) bool { return v >= 100 || v%2 == 1 })
+	RemoveAll(cp, 51)
+	cp.RemoveAt(10)
+	cp.Insert(20, []int{-1, -2})
+	got := cp.PopOlderThan(25 * time.Millisecond)
+	if len(got) != 50 || slices.ContainsFunc(got, func(v int) bool { return v >= 100 }) {
+		t.Errorf("PopOlderThan returned %d elements: %v", len(got), got)
+	}
+	if cp.Len() != 100 {
+		t.Errorf("Len = %d, want the 100 fresh elements", cp.Len())
+	}
+	if v, _ := cp.PeekFront(); v != 100 {
+		t.Errorf("PeekFront = %d, want 100", v)
+	}
+}
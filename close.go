@@ -0,0 +1,50 @@
+package chunkpipe
+
+import "errors"
+
+// ErrClosed 表示管道已經關閉，不再接受推入
+var ErrClosed = errors.New("chunkpipe: pipe is closed")
+
+// Close 關閉管道，表示不會再有新的資料。關閉後：
+//   - Push 等不返回錯誤的推入會捨棄資料，TryPush 與 Write 返回 ErrClosed
+//   - 已在管道中的資料仍可正常取出，PopFrontCtx 等阻塞彈出在取完後返回 io.EOF
+//   - 阻塞中的推入與彈出都會被喚醒，Notify 的接收者也會收到一次通知
+//   - 空閒列表中由 WithAllocator 或 WithOffHeap 配置的區塊會交還給配置器，之後取空的區塊也直接交還
+//   - WithTTL 啟動的背景清理會停止，剩下的元素不再因過期被移除
+//
+// 重複呼叫 Close 沒有作用
+func (cl *ChunkPipe[T]) Close() error {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.closed {
+		return nil
+	}
+	cl.closed = true
+	// 關閉後不再推入，空閒列表中外部配置的區塊不會再被使用
+	cl.releaseFree()
+	cl.signal()
+	if cl.space != nil {
+		close(cl.space)
+		cl.space = nil
+	}
+	return nil
+}
+
+// Closed 返回管道是否已經關閉
+func (cl *ChunkPipe[T]) Closed() bool {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	return cl.closed
+}
+
+// lockPush 取得寫鎖準備推入；管道已關閉時釋放鎖並返回 false
+func (cl *ChunkPipe[T]) lockPush() bool {
+	cl.mu.Lock()
+	if cl.closed {
+		cl.mu.Unlock()
+		return false
+	}
+	return true
+}
@@ -0,0 +1,57 @@
+package chunkpipe
+
+// Cursor 是可定位的惰性迭代器，快取目前所在的區塊，循序呼叫 Next 為 O(1)。
+//
+// 游標記錄的是元素在管道中的絕對位置，因此迭代期間修改管道是安全的：
+// 已被彈出的元素會被略過，Next 繼續返回上一個元素之後仍存在的元素，
+// 尾端新推入的元素也會被走訪到
+type Cursor[T any] struct {
+	pipe  *ChunkPipe[T]
+	pos   int // 下一個元素的絕對位置
+	chunk int // 快取的區塊索引
+}
+
+// Next 返回下一個元素，沒有更多元素時返回 false
+func (c *Cursor[T]) Next() (T, bool) {
+	cl := c.pipe
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if c.pos < cl.offset {
+		c.pos = cl.offset
+	}
+
+	if c.chunk < len(cl.list) {
+		off := cl.list[c.chunk]
+		start := off.off - len(off.val)
+		if c.pos >= start && c.pos < off.off {
+			ret := off.val[c.pos-start]
+			c.pos++
+			return ret, true
+		}
+	}
+
+	i, pos, ok := cl.find(c.pos)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	c.chunk = i
+	c.pos++
+	return cl.list[i].val[pos], true
+}
+
+// Seek 將游標移動到邏輯索引 index，下一次 Next 會返回該元素
+func (c *Cursor[T]) Seek(index int) {
+	cl := c.pipe
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	c.pos = cl.offset + max(index, 0)
+	c.chunk = 0
+}
+
+// Reset 將游標移回目前的頭部
+func (c *Cursor[T]) Reset() {
+	c.Seek(0)
+}
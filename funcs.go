@@ -0,0 +1,213 @@
+package chunkpipe
+
+import (
+	"cmp"
+	"slices"
+	"unsafe"
+)
+
+// ToMap 統計每個值出現的次數，直接走訪區塊而不建立中間切片
+func ToMap[T comparable](cl *ChunkPipe[T]) map[T]int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	ret := make(map[T]int)
+	for i := range cl.list {
+		for _, v := range cl.list[i].val {
+			ret[v]++
+		}
+	}
+	return ret
+}
+
+// Sort 將有序型別的元素遞增排序，區塊內使用 slices.Sort，比 Sort 方法少了比較函式的開銷
+func Sort[T cmp.Ordered](cl *ChunkPipe[T]) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.sort(slices.Sort[[]T], cmp.Less[T])
+}
+
+// IsSorted 回報有序型別的元素是否已遞增排序
+func IsSorted[T cmp.Ordered](cl *ChunkPipe[T]) bool {
+	return cl.IsSorted(cmp.Less[T])
+}
+
+// Number 為 Sum 可累加的整數與浮點數型別
+type Number interface {
+	~int | ~int8 | ~int16 | ~int32 | ~int64 |
+		~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 | ~uintptr |
+		~float32 | ~float64
+}
+
+// Min 返回最小的元素，空管道返回 false。
+// 直接在讀鎖內逐區塊計算，不複製元素；浮點數遇到 NaN 時返回 NaN
+func Min[T cmp.Ordered](cl *ChunkPipe[T]) (T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	var ret T
+	for i := range cl.list {
+		m := slices.Min(cl.list[i].val)
+		if i == 0 {
+			ret = m
+		} else {
+			ret = min(ret, m)
+		}
+	}
+	return ret, len(cl.list) > 0
+}
+
+// Max 返回最大的元素，空管道返回 false
+func Max[T cmp.Ordered](cl *ChunkPipe[T]) (T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	var ret T
+	for i := range cl.list {
+		m := slices.Max(cl.list[i].val)
+		if i == 0 {
+			ret = m
+		} else {
+			ret = max(ret, m)
+		}
+	}
+	return ret, len(cl.list) > 0
+}
+
+// Sum 返回所有元素的總和，整數溢位時依 Go 的規則迴繞
+func Sum[T Number](cl *ChunkPipe[T]) T {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	var ret T
+	for i := range cl.list {
+		var s T
+		for _, v := range cl.list[i].val {
+			s += v
+		}
+		ret += s
+	}
+	return ret
+}
+
+// Remove 移除第一個等於 v 的元素，返回是否有元素被移除
+func Remove[T comparable](cl *ChunkPipe[T], v T) bool {
+	cl.mu.Lock()
+	for i := range cl.list {
+		if pos := slices.Index(cl.list[i].val, v); pos >= 0 {
+			cl.removeAt(i, pos)
+			cl.unlock(0, 1)
+			return true
+		}
+	}
+	cl.mu.Unlock()
+	return false
+}
+
+// RemoveAll 移除所有等於 v 的元素，返回移除的數量。
+// 自有區塊原地壓縮，含有 v 的借用區塊會複製成新的自有區塊
+func RemoveAll[T comparable](cl *ChunkPipe[T], v T) int {
+	cl.mu.Lock()
+	removed := cl.filter(func(x T) bool { return x != v })
+	cl.unlock(0, removed)
+	return removed
+}
+
+// Reduce 依序以 fn 將所有元素累積到 init 上並返回結果，直接在讀鎖內走訪區塊，fn 不可修改管道
+func Reduce[T, A any](cl *ChunkPipe[T], init A, fn func(A, T) A) A {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	acc := init
+	for i := range cl.list {
+		for _, v := range cl.list[i].val {
+			acc = fn(acc, v)
+		}
+	}
+	return acc
+}
+
+// CloneFunc 深度複製 cl，並對每個元素套用 transform，來源管道不受影響。
+// 新管道沿用 cl 的設定，所有元素存放在單一自有數據塊中
+func CloneFunc[T any](cl *ChunkPipe[T], transform func(T) T) *ChunkPipe[T] {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	ret := cl.newLike()
+	n := cl.len()
+	if n == 0 {
+		return ret
+	}
+
+	val := make([]T, 0, n)
+	for i := range cl.list {
+		for _, v := range cl.list[i].val {
+			val = append(val, transform(v))
+		}
+	}
+	ret.pushOwned(val)
+	return ret
+}
+
+// EqualFunc 以 eq 逐一比較兩個管道的元素，與數據塊的切分方式無關；長度不同時直接返回 false
+func EqualFunc[T any](a, b *ChunkPipe[T], eq func(x, y T) bool) bool {
+	if a == b {
+		return true
+	}
+	unlock := rlockPair(a, b)
+	defer unlock()
+
+	if a.len() != b.len() {
+		return false
+	}
+
+	var bi, bpos int
+	for i := range a.list {
+		for _, x := range a.list[i].val {
+			if !eq(x, b.list[bi].val[bpos]) {
+				return false
+			}
+			if bpos++; bpos == len(b.list[bi].val) {
+				bi, bpos = bi+1, 0
+			}
+		}
+	}
+	return true
+}
+
+// rlockPair 依記憶體位址順序取得兩個不同管道的讀鎖，並返回解鎖函式
+func rlockPair[T any](a, b *ChunkPipe[T]) func() {
+	if uintptr(unsafe.Pointer(a)) > uintptr(unsafe.Pointer(b)) {
+		a, b = b, a
+	}
+	a.mu.RLock()
+	b.mu.RLock()
+	return func() {
+		b.mu.RUnlock()
+		a.mu.RUnlock()
+	}
+}
+
+// lockPair 依記憶體位址順序取得兩個不同管道的寫鎖
+func lockPair[T any](a, b *ChunkPipe[T]) {
+	if uintptr(unsafe.Pointer(a)) > uintptr(unsafe.Pointer(b)) {
+		a, b = b, a
+	}
+	a.mu.Lock()
+	b.mu.Lock()
+}
+
+// unlockMove 釋放 lockPair 取得的寫鎖，並在兩者都解鎖後觸發 n 個元素
+// 由 src 移到 dst 的回呼，避免回呼存取另一個管道時死鎖
+func unlockMove[T any](dst, src *ChunkPipe[T], n int) {
+	dst.autoCompact()
+	dst.emit(OpPush, n)
+	src.emit(OpPop, n)
+	dstHooks := dst.release(n, 0)
+	srcHooks := src.release(0, n)
+	dst.mu.Unlock()
+	src.mu.Unlock()
+	dstHooks.run(n, 0)
+	srcHooks.run(0, n)
+}
@@ -1,11 +1,3 @@
 module github.com/HazelnutParadise/go-chunkpipe
 
-go 1.22.7
-
-require github.com/VictoriaMetrics/fastcache v1.12.2
-
-require (
-	github.com/cespare/xxhash/v2 v2.2.0 // indirect
-	github.com/golang/snappy v0.0.4 // indirect
-	golang.org/x/sys v0.14.0 // indirect
-)
+go 1.23
@@ -1,65 +1,74 @@
 package chunkpipe
 
 import (
-	"encoding/binary"
+	"sync"
 	"unsafe"
-
-	"github.com/VictoriaMetrics/fastcache"
 )
 
-// memoryPool 使用 fastcache 的記憶體池
+// memoryPoolLimit 為記憶體池保留的已釋放記憶體上限
+const memoryPoolLimit = 512 * 1024 * 1024 // 512MB
+
+// memoryPool 依大小保留已釋放的記憶體區塊供之後重複使用。
+// 區塊以 []byte 保存，垃圾回收器看得到這些引用，
+// 因此池中的記憶體在被重新分配之前不會被回收，Alloc 返回的指標也不會懸空
 type memoryPool struct {
-	cache *fastcache.Cache
+	mu   sync.Mutex
+	free map[uintptr][][]byte
+	size int
 }
 
 // newMemoryPool 創建一個新的記憶體池
 func newMemoryPool() *memoryPool {
 	return &memoryPool{
-		cache: fastcache.New(512 * 1024 * 1024), // 512MB 快取
+		free: make(map[uintptr][][]byte),
 	}
 }
 
-// Alloc 分配指定大小的記憶體
+// Alloc 分配指定大小的記憶體，優先使用池中相同大小的區塊
 func (p *memoryPool) Alloc(size uintptr) unsafe.Pointer {
-	// 使用大小作為 key
-	key := make([]byte, 8)
-	binary.LittleEndian.PutUint64(key, uint64(size))
-
-	// 嘗試從快取獲取
-	value := p.cache.Get(nil, key)
-	if len(value) > 0 {
-		return *(*unsafe.Pointer)(unsafe.Pointer(&value[0]))
+	p.mu.Lock()
+	if blocks := p.free[size]; len(blocks) > 0 {
+		b := blocks[len(blocks)-1]
+		blocks[len(blocks)-1] = nil
+		p.free[size] = blocks[:len(blocks)-1]
+		p.size -= len(b)
+		p.mu.Unlock()
+		clear(b)
+		return unsafe.Pointer(unsafe.SliceData(b))
 	}
+	p.mu.Unlock()
 
 	// 分配新記憶體
-	ptr := unsafe.Pointer(&make([]byte, size)[0])
-	return ptr
+	return unsafe.Pointer(unsafe.SliceData(make([]byte, max(size, 1))))
 }
 
-// Free 釋放記憶體到快取
+// Free 將由 Alloc 分配、大小為 size 的記憶體放回池中，超過上限時交給垃圾回收器
 func (p *memoryPool) Free(ptr unsafe.Pointer, size uintptr) {
-	// 準備 value
-	value := make([]byte, unsafe.Sizeof(uintptr(0)))
-	*(*unsafe.Pointer)(unsafe.Pointer(&value[0])) = ptr
-
-	// 使用大小作為 key
-	key := make([]byte, 8)
-	binary.LittleEndian.PutUint64(key, uint64(size))
+	if ptr == nil {
+		return
+	}
+	b := unsafe.Slice((*byte)(ptr), max(size, 1))
 
-	// 存入快取
-	p.cache.Set(key, value)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.size+len(b) > memoryPoolLimit {
+		return
+	}
+	p.free[size] = append(p.free[size], b)
+	p.size += len(b)
 }
 
-// Size 返回已分配的記憶體總大小
+// Size 返回池中保留的記憶體總大小
 func (p *memoryPool) Size() int {
-	// 使用 UpdateStats 並返回實際使用的記憶體大小
-	var stats fastcache.Stats
-	p.cache.UpdateStats(&stats)
-	return int(stats.BytesSize)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.size
 }
 
-// Reset 重置記憶體池
+// Reset 清空記憶體池
 func (p *memoryPool) Reset() {
-	// 重新創建快取
-	p.cache = fastcache.New(512 * 1024 * 1024)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = make(map[uintptr][][]byte)
+	p.size = 0
 }
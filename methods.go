@@ -1,105 +1,1742 @@
 package chunkpipe
 
-// 插入數據到 ChunkPipe，支援泛型和鏈式呼叫
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"reflect"
+	"slices"
+)
+
+// 插入數據到 ChunkPipe，支援泛型和鏈式呼叫。
+// 預設會複製 data；以 WithCopyOnPush(false) 建立的管道會直接引用較大的切片。
+// 以 NewBounded 建立的管道已滿時會阻塞到有足夠空間，或依 WithOverflow 的策略處理；管道關閉後推入的資料會被捨棄
 func (cl *ChunkPipe[T]) Push(data []T) *ChunkPipe[T] {
+	if len(data) == 0 {
+		return cl
+	}
+	if cl.maxLen > 0 {
+		cl.pushBounded(data, cl.push, false)
+		return cl
+	}
+
+	if !cl.lockPush() {
+		return cl
+	}
+	wasEmpty := len(cl.list) == 0
+	cl.push(data)
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(len(data), 0)
+	return cl
+}
+
+// PushOne 將單一元素加入尾端，直接寫入尾端自有區塊的剩餘空間，
+// 不需要為了呼叫 Push 建立切片；只在需要新區塊時才配置記憶體
+func (cl *ChunkPipe[T]) PushOne(v T) *ChunkPipe[T] {
+	if cl.maxLen > 0 {
+		cl.pushBounded([]T{v}, cl.pushCopy, false)
+		return cl
+	}
+
+	if !cl.lockPush() {
+		return cl
+	}
+	wasEmpty := len(cl.list) == 0
+	if tail := cl.tail(); tail != nil && cl.room(tail) > 0 {
+		tail.val = append(tail.val, v)
+		tail.off++
+	} else {
+		cl.pushOwned(append(cl.allocChunk(1), v))
+	}
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(1, 0)
+	return cl
+}
+
+// tail 返回尾端區塊，管道為空時返回 nil，呼叫者需持有鎖
+func (cl *ChunkPipe[T]) tail() *offset[T] {
+	if len(cl.list) == 0 {
+		return nil
+	}
+	return &cl.list[len(cl.list)-1]
+}
+
+// PushAll 在同一次加鎖中依序推入多個切片，空切片會被略過；
+// 有上限的管道改為逐一以 Push 的方式推入
+func (cl *ChunkPipe[T]) PushAll(datas ...[]T) *ChunkPipe[T] {
+	if cl.maxLen > 0 {
+		for _, data := range datas {
+			cl.pushBounded(data, cl.push, false)
+		}
+		return cl
+	}
+
+	if !cl.lockPush() {
+		return cl
+	}
+	wasEmpty := len(cl.list) == 0
+	n := 0
+	for _, data := range datas {
+		if len(data) == 0 {
+			continue
+		}
+		cl.push(data)
+		n += len(data)
+	}
+	if wasEmpty && n > 0 {
+		cl.signal()
+	}
+	cl.unlock(n, 0)
+	return cl
+}
+
+// PushCopy 將 data 複製到管道自有的記憶體，不受 WithCopyOnPush 設定影響
+func (cl *ChunkPipe[T]) PushCopy(data []T) *ChunkPipe[T] {
+	if len(data) == 0 {
+		return cl
+	}
+	if cl.maxLen > 0 {
+		cl.pushBounded(data, cl.pushCopy, false)
+		return cl
+	}
+
+	if !cl.lockPush() {
+		return cl
+	}
+	wasEmpty := len(cl.list) == 0
+	cl.pushCopy(data)
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(len(data), 0)
+	return cl
+}
+
+// PushOwned 不複製地將 data 鏈接為新的尾端區塊，並將 data 的所有權交給管道：
+// 之後的推入可能直接寫入 data 的剩餘容量，呼叫者不可再讀寫 data
+func (cl *ChunkPipe[T]) PushOwned(data []T) *ChunkPipe[T] {
+	if len(data) == 0 {
+		return cl
+	}
+	if cl.maxLen > 0 {
+		cl.pushBounded(data, cl.pushOwned, false)
+		return cl
+	}
+
+	if !cl.lockPush() {
+		return cl
+	}
+	wasEmpty := len(cl.list) == 0
+	cl.pushOwned(data)
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(len(data), 0)
+	return cl
+}
+
+// PushFront 將 data 鏈接為新的頭部區塊，data 的順序保持不變。
+// 複製規則與 Push 相同，有上限的管道已滿時會阻塞到有足夠空間
+func (cl *ChunkPipe[T]) PushFront(data []T) *ChunkPipe[T] {
+	if len(data) == 0 {
+		return cl
+	}
+	if cl.maxLen > 0 {
+		cl.pushBounded(data, cl.pushFront, true)
+		return cl
+	}
+
+	if !cl.lockPush() {
+		return cl
+	}
+	wasEmpty := len(cl.list) == 0
+	cl.pushFront(data)
+	if wasEmpty {
+		cl.signal()
+	}
+	cl.unlock(len(data), 0)
+	return cl
+}
+
+// PushFrontOne 將單一元素加入頭部
+func (cl *ChunkPipe[T]) PushFrontOne(v T) *ChunkPipe[T] {
+	return cl.PushFront([]T{v})
+}
+
+// pushFront 將數據鏈接到頭部，呼叫者需持有寫鎖。
+// 頭部沒有可以原地寫入的空間，因此每次都是新的區塊，複製時只配置剛好的容量
+func (cl *ChunkPipe[T]) pushFront(data []T) {
+	c := offset[T]{val: data, off: cl.offset}
+	if !cl.alias || len(data) <= smallPushSize {
+		c.val = append(make([]T, 0, len(data)), data...)
+		c.cap = cap(c.val)
+	}
+	cl.offset -= len(data)
+	cl.list = slices.Insert(cl.list, 0, c)
+	cl.untimed += len(data)
+}
+
+// push 將數據鏈接到尾端，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) push(data []T) {
+	if !cl.alias || len(data) <= smallPushSize || cl.normalize && len(data) < cl.chunkCap() {
+		cl.pushCopy(data)
+		return
+	}
+
+	off := cl.offset
+	if len(cl.list) != 0 {
+		off = cl.list[len(cl.list)-1].off
+	}
+	// 正規化時將過大的切片切成多個區塊大小的視圖，仍然不複製
+	size := len(data)
+	if cl.normalize {
+		size = cl.chunkCap()
+	}
+	for len(data) > 0 {
+		k := min(size, len(data))
+		off += k
+		cl.list = append(cl.list, offset[T]{
+			val: data[:k:k],
+			off: off,
+		})
+		data = data[k:]
+	}
+}
+
+// pushCopy 將 data 複製到尾端，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) pushCopy(data []T) {
+	if len(cl.list) != 0 {
+		tail := &cl.list[len(cl.list)-1]
+
+		// 直接追加到尾端的自有區塊，僅在剩餘容量足夠時才原地寫入
+		if len(data) <= cl.room(tail) {
+			tail.val = append(tail.val, data...)
+			tail.off += len(data)
+			return
+		}
+		// 正規化時先填滿尾端區塊，其餘依區塊大小分段寫入新的區塊
+		if cl.normalize {
+			k := cl.room(tail)
+			tail.val = append(tail.val, data[:k]...)
+			tail.off += k
+			data = data[k:]
+		}
+	}
+	if cl.normalize {
+		size := cl.chunkCap()
+		for len(data) > size {
+			cl.pushOwned(append(cl.allocChunk(size), data[:size]...))
+			data = data[size:]
+		}
+	}
+	cl.pushOwned(append(cl.allocChunk(len(data)), data...))
+}
+
+// room 返回區塊 c 還能原地追加的元素數量；
+// 正規化時區塊中的元素不超過區塊大小，即使底層陣列還有空間
+func (cl *ChunkPipe[T]) room(c *offset[T]) int {
+	n := c.cap - len(c.val)
+	if cl.normalize {
+		n = min(n, cl.chunkCap()-len(c.val))
+	}
+	return max(n, 0)
+}
+
+// clearSlots 將區塊 c 中已移出的 slots 寫為零值，讓其引用的物件可以被回收。
+// 只處理管道自有的區塊，且元素型別不含指標時直接略過
+func (cl *ChunkPipe[T]) clearSlots(c offset[T], slots []T) {
+	if cl.pointers && c.cap > 0 {
+		clear(slots)
+	}
+}
+
+// hasPointers 判斷型別 t 的值是否可能包含指標
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Array:
+		return t.Len() > 0 && hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+		return false
+	case reflect.Pointer, reflect.UnsafePointer, reflect.Map, reflect.Slice,
+		reflect.String, reflect.Interface, reflect.Chan, reflect.Func:
+		return true
+	default:
+		return false
+	}
+}
+
+// newLike 建立一個與 cl 設定相同的空管道，呼叫者需持有鎖。
+// 推入時間不會帶到新管道，因此新管道不記錄時間，也不啟動背景清理
+func (cl *ChunkPipe[T]) newLike() *ChunkPipe[T] {
+	ret := &ChunkPipe[T]{
+		chunkSize:   cl.chunkSize,
+		noTree:      cl.noTree,
+		alias:       cl.alias,
+		pointers:    cl.pointers,
+		maxLen:      cl.maxLen,
+		overflow:    cl.overflow,
+		metrics:     cl.metrics,
+		trace:       cl.trace,
+		allocator:   cl.allocator,
+		compactFill: cl.compactFill,
+		trimRatio:   cl.trimRatio,
+		normalize:   cl.normalize,
+	}
+	ret.mu.disabled = cl.mu.disabled
+	return ret
+}
+
+// pushOwned 將管道自有的切片直接鏈接為新的尾端區塊，呼叫者需持有寫鎖。
+// 正規化時過大的切片切成多個區塊大小、限制容量的區塊，只有最後一塊保留剩餘容量
+func (cl *ChunkPipe[T]) pushOwned(val []T) {
+	off := cl.offset
+	if len(cl.list) != 0 {
+		off = cl.list[len(cl.list)-1].off
+	}
+	if cl.normalize {
+		for size := cl.chunkCap(); len(val) > size; val = val[size:] {
+			off += size
+			cl.list = append(cl.list, offset[T]{
+				val: val[:size:size],
+				off: off,
+				cap: size,
+			})
+		}
+	}
+	cl.list = append(cl.list, offset[T]{
+		val: val,
+		off: off + len(val),
+		cap: cap(val),
+	})
+}
+
+// OnPush 註冊在元素加入後呼叫的回呼，n 為加入的元素數量。
+// 回呼在釋放鎖之後執行，傳入 nil 可取消註冊
+func (cl *ChunkPipe[T]) OnPush(fn func(n int)) *ChunkPipe[T] {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.onPush = fn
+	return cl
+}
+
+// OnPop 註冊在元素移除後呼叫的回呼，n 為移除的元素數量。
+// 回呼在釋放鎖之後執行，傳入 nil 可取消註冊
+func (cl *ChunkPipe[T]) OnPop(fn func(n int)) *ChunkPipe[T] {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.onPop = fn
+	return cl
+}
+
+// OnEvict 註冊在元素被丟棄時呼叫的回呼，包含 WithOverflow 策略移除或捨棄的元素，以及 Clear 清除的元素；
+// batch 為被丟棄元素的副本，可用來釋放元素持有的資源或記錄資料遺失。
+// 回呼在釋放鎖之後執行，傳入 nil 可取消註冊
+func (cl *ChunkPipe[T]) OnEvict(fn func(batch []T)) *ChunkPipe[T] {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.onEvict = fn
+	return cl
+}
+
+// evict 在註冊了 OnEvict 時記錄從絕對位置 target 起即將被丟棄的 n 個元素，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) evict(target, n int) {
+	if cl.onEvict == nil || n <= 0 {
+		return
+	}
+	k := len(cl.evicted)
+	cl.evicted = slices.Grow(cl.evicted, n)[:k+n]
+	cl.copyAt(cl.evicted[k:], target)
+}
+
+// evictData 在註冊了 OnEvict 時記錄沒有推入就被捨棄的 data，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) evictData(data []T) {
+	if cl.onEvict != nil {
+		cl.evicted = append(cl.evicted, data...)
+	}
+}
+
+// Notify 返回一個在管道由空轉為非空時收到通知的通道。
+// 通知會合併且不阻塞推入者，收到通知後應持續取出直到管道為空。
+// 通知可能已過時：其他消費者或整批取出可能先一步清空管道，
+// 此時取不到資料就重新等待，下一次由空轉為非空時會再次通知
+func (cl *ChunkPipe[T]) Notify() <-chan struct{} {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.notify == nil {
+		cl.notify = make(chan struct{}, 1)
+	}
+	return cl.notify
+}
+
+// signal 以非阻塞方式發送資料可用通知，並喚醒阻塞中的彈出，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) signal() {
+	if cl.ready != nil {
+		close(cl.ready)
+		cl.ready = nil
+	}
+	if cl.notify == nil {
+		return
+	}
+	select {
+	case cl.notify <- struct{}{}:
+	default:
+	}
+}
+
+// unlock 釋放寫鎖，並在鎖外觸發推入與彈出的回呼；
+// 有元素被移除時一併喚醒等待空間的推入
+func (cl *ChunkPipe[T]) unlock(pushed, popped int) {
+	cl.autoCompact()
+	cl.emit(OpPush, pushed)
+	cl.emit(OpPop, popped)
+	h := cl.release(pushed, popped)
+	cl.mu.Unlock()
+	h.run(pushed, popped)
+}
+
+// hooks 是釋放寫鎖前取出、在鎖外觸發的回呼與指標收集器
+type hooks struct {
+	onPush, onPop func(n int)
+	// evict 將鎖內收集的被丟棄元素交給 onEvict，沒有時為 nil
+	evict   func()
+	metrics Collector
+	// allocs 為上次回報之後新配置的區塊數
+	allocs int
+}
+
+// release 在釋放寫鎖前更新累計計數、喚醒等待空間的推入，並返回要在鎖外觸發的回呼
+func (cl *ChunkPipe[T]) release(pushed, popped int) hooks {
+	cl.totalPushed += uint64(pushed)
+	cl.totalPopped += uint64(popped)
+	if cl.stamped {
+		if n := pushed - cl.untimed; n > 0 {
+			cl.stamp(n)
+		}
+		cl.untimed = 0
+	}
+	if popped > 0 && cl.space != nil {
+		close(cl.space)
+		cl.space = nil
+	}
+	h := hooks{onPush: cl.onPush, onPop: cl.onPop, metrics: cl.metrics}
+	if len(cl.evicted) > 0 {
+		fn, batch := cl.onEvict, cl.evicted
+		cl.evicted = nil
+		h.evict = func() { fn(batch) }
+	}
+	if cl.metrics != nil {
+		h.allocs = int(cl.chunksAllocated - cl.reportedAllocs)
+		cl.reportedAllocs = cl.chunksAllocated
+	}
+	return h
+}
+
+// run 觸發推入與彈出的回呼並回報指標，必須在釋放鎖之後呼叫
+func (h hooks) run(pushed, popped int) {
+	if pushed > 0 && h.onPush != nil {
+		h.onPush(pushed)
+	}
+	if popped > 0 && h.onPop != nil {
+		h.onPop(popped)
+	}
+	if h.evict != nil {
+		h.evict()
+	}
+	if h.metrics == nil {
+		return
+	}
+	if pushed > 0 {
+		h.metrics.Pushed(pushed)
+	}
+	if popped > 0 {
+		h.metrics.Popped(popped)
+	}
+	if h.allocs > 0 {
+		h.metrics.ChunksAllocated(h.allocs)
+	}
+}
+
+// allocChunk 取得一個至少可容納 n 個元素的自有區塊，
+// 優先使用預先分配的區塊，其次是空閒列表與歸還到池中的區塊
+func (cl *ChunkPipe[T]) allocChunk(n int) []T {
+	if cap(cl.spare) >= n {
+		val := cl.spare
+		cl.spare = nil
+		cl.chunksReused++
+		return val
+	}
+	if k := len(cl.free) - 1; k >= 0 && cap(cl.free[k]) >= n {
+		val := cl.free[k]
+		cl.free[k] = nil
+		cl.free = cl.free[:k]
+		cl.chunksReused++
+		return val
+	}
+	if p, ok := cl.pool.Get().(*[]T); ok && cap(*p) >= n {
+		cl.chunksReused++
+		return (*p)[:0]
+	}
+	return cl.newChunk(max(n, cl.chunkCap()))
+}
+
+// newChunk 配置一個容量為 n 的新自有區塊，有設定 allocator 時優先由它配置
+func (cl *ChunkPipe[T]) newChunk(n int) []T {
+	cl.chunksAllocated++
+	if cl.allocator != nil {
+		if val := cl.allocExternal(n); val != nil {
+			return val
+		}
+	}
+	return make([]T, 0, n)
+}
+
+// Reserve 預先配置可容納 n 個元素的自有區塊並放入空閒列表，
+// 之後合計不超過 n 個元素的推入不需再配置記憶體，類似預留切片的容量。
+// 適合在延遲敏感的突發推入之前呼叫；開啟 WithChunkNormalization 時依區塊大小分成多塊預留
+func (cl *ChunkPipe[T]) Reserve(n int) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if n <= 0 {
+		return
+	}
+	if !cl.normalize {
+		if cap(cl.spare) >= n || len(cl.free) > 0 && cap(cl.free[len(cl.free)-1]) >= n {
+			return
+		}
+		cl.free = append(cl.free, cl.newChunk(max(n, cl.chunkCap())))
+		cl.list = slices.Grow(cl.list, 1)
+		return
+	}
+
+	size := cl.chunkCap()
+	have := 0
+	for _, val := range cl.free {
+		if cap(val) >= size {
+			have += size
+		}
+	}
+	for ; have < n; have += size {
+		cl.free = append(cl.free, cl.newChunk(size))
+	}
+	// 區塊列表本身也預留空間，推入時連結新區塊不需擴充
+	cl.list = slices.Grow(cl.list, (n+size-1)/size+1)
+}
+
+// chunkCap 返回新配置自有區塊的容量
+func (cl *ChunkPipe[T]) chunkCap() int {
+	if cl.chunkSize <= 0 {
+		return defaultChunkSize
+	}
+	return cl.chunkSize
+}
+
+// locate 找出邏輯索引所在的區塊及其在區塊內的位置
+func (cl *ChunkPipe[T]) locate(index int) (int, int, bool) {
+	if index < 0 {
+		return 0, 0, false
+	}
+	return cl.find(index + cl.offset)
+}
+
+// find 找出絕對位置 target 所在的區塊及其在區塊內的位置。
+// 每個區塊記錄累計的絕對結束位置，推入與彈出時只需更新端點的區塊，
+// 因此啟用索引時可直接二分搜尋，為 O(log 區塊數)
+func (cl *ChunkPipe[T]) find(target int) (int, int, bool) {
+	if len(cl.list) == 0 || target < cl.offset {
+		return 0, 0, false
+	}
+
+	l := 0
+	r := len(cl.list) - 1
+
+	if target >= cl.list[r].off {
+		return 0, 0, false
+	}
+
+	if cl.noTree {
+		for i := range cl.list {
+			if cl.list[i].off > target {
+				r = i
+				break
+			}
+		}
+	} else if cl.list[l].off > target {
+		r = l
+	} else {
+		for r-l > 1 {
+			m := (r + l) >> 1
+			if cl.list[m].off > target {
+				r = m
+			} else {
+				l = m
+			}
+		}
+	}
+
+	off := cl.list[r]
+	return r, len(off.val) - (off.off - target), true
+}
+
+func (cl *ChunkPipe[T]) Get(index int) (T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	i, pos, ok := cl.locate(index)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+	return cl.list[i].val[pos], true
+}
+
+// Set 將邏輯索引 index 的元素設為 v；索引無效時返回 false
+func (cl *ChunkPipe[T]) Set(index int, v T) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	i, pos, ok := cl.locate(index)
+	if !ok {
+		return false
+	}
+	cl.unshare(i)
+	cl.list[i].val[pos] = v
+	return true
+}
+
+// At 返回邏輯索引 index 的元素指標，可直接原地修改；索引無效時返回 nil。
+// 指標在鎖外使用，只在下一次修改管道之前有效，並發修改管道時不安全
+func (cl *ChunkPipe[T]) At(index int) *T {
 	cl.mu.Lock()
 	defer cl.mu.Unlock()
 
-	if len(data) == 0 {
-		return cl
+	i, pos, ok := cl.locate(index)
+	if !ok {
+		return nil
+	}
+	cl.unshare(i)
+	return &cl.list[i].val[pos]
+}
+
+// EnableIndex 讓 Get 等隨機存取改用區塊偏移索引做二分搜尋。
+// 偏移本身隨每次推入與彈出維護，不需額外建立
+func (cl *ChunkPipe[T]) EnableIndex() {
+	cl.mu.Lock()
+	cl.noTree = false
+	cl.mu.Unlock()
+}
+
+// DisableIndex 讓隨機存取改為線性掃描區塊，適合幾乎只從兩端存取的用法
+func (cl *ChunkPipe[T]) DisableIndex() {
+	cl.mu.Lock()
+	cl.noTree = true
+	cl.mu.Unlock()
+}
+
+// Head 在同一次讀鎖內返回頭部元素與當前長度，兩者必定來自同一時刻
+func (cl *ChunkPipe[T]) Head() (value T, length int, ok bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	length = cl.len()
+	if length == 0 {
+		return value, 0, false
+	}
+	return cl.list[0].val[0], length, true
+}
+
+// GetMany 一次取得多個索引的元素，返回值與是否存在的旗標依 indices 原本的順序排列。
+// 索引會先排序，再單向走訪一次區塊列表
+func (cl *ChunkPipe[T]) GetMany(indices []int) ([]T, []bool) {
+	vals := make([]T, len(indices))
+	found := make([]bool, len(indices))
+	order := make([]int, len(indices))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortFunc(order, func(a, b int) int {
+		return cmp.Compare(indices[a], indices[b])
+	})
+
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	c, n := 0, cl.len()
+	for _, k := range order {
+		if indices[k] < 0 {
+			continue
+		}
+		if indices[k] >= n {
+			break
+		}
+		target := indices[k] + cl.offset
+		for c < len(cl.list) && cl.list[c].off <= target {
+			c++
+		}
+		if c == len(cl.list) {
+			break
+		}
+		off := cl.list[c]
+		vals[k] = off.val[len(off.val)-(off.off-target)]
+		found[k] = true
+	}
+	return vals, found
+}
+
+// PeekFront 返回頭部元素但不彈出
+func (cl *ChunkPipe[T]) PeekFront() (T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if len(cl.list) == 0 {
+		var zero T
+		return zero, false
+	}
+	return cl.list[0].val[0], true
+}
+
+// PeekEnd 返回尾端元素但不彈出
+func (cl *ChunkPipe[T]) PeekEnd() (T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if len(cl.list) == 0 {
+		var zero T
+		return zero, false
+	}
+	val := cl.list[len(cl.list)-1].val
+	return val[len(val)-1], true
+}
+
+// IndexOf 返回第一個與 v 相等的元素索引，找不到時返回 -1。
+// 直接在讀鎖內走訪區塊而不複製，eq 不可修改管道
+func (cl *ChunkPipe[T]) IndexOf(v T, eq func(a, b T) bool) int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	for i := range cl.list {
+		c := cl.list[i]
+		if pos := slices.IndexFunc(c.val, func(x T) bool { return eq(x, v) }); pos >= 0 {
+			return c.off - len(c.val) - cl.offset + pos
+		}
+	}
+	return -1
+}
+
+// LastIndexOf 返回最後一個與 v 相等的元素索引，找不到時返回 -1
+func (cl *ChunkPipe[T]) LastIndexOf(v T, eq func(a, b T) bool) int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	for i := len(cl.list) - 1; i >= 0; i-- {
+		c := cl.list[i]
+		for pos := len(c.val) - 1; pos >= 0; pos-- {
+			if eq(c.val[pos], v) {
+				return c.off - len(c.val) - cl.offset + pos
+			}
+		}
+	}
+	return -1
+}
+
+// Contains 回報是否有元素與 v 相等
+func (cl *ChunkPipe[T]) Contains(v T, eq func(a, b T) bool) bool {
+	return cl.IndexOf(v, eq) >= 0
+}
+
+// Count 返回滿足 pred 的元素數量，直接在讀鎖內走訪區塊，pred 不可修改管道
+func (cl *ChunkPipe[T]) Count(pred func(T) bool) int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	n := 0
+	for i := range cl.list {
+		for _, v := range cl.list[i].val {
+			if pred(v) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// Any 回報是否有任一元素滿足 pred
+func (cl *ChunkPipe[T]) Any(pred func(T) bool) bool {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	for i := range cl.list {
+		if slices.ContainsFunc(cl.list[i].val, pred) {
+			return true
+		}
+	}
+	return false
+}
+
+// Every 回報是否所有元素都滿足 pred，空管道返回 true。
+// All 已是走訪元素的迭代器，因此以 Every 命名
+func (cl *ChunkPipe[T]) Every(pred func(T) bool) bool {
+	return !cl.Any(func(v T) bool { return !pred(v) })
+}
+
+// Equal 以 eq 逐一比較 cl 與 other 的內容，與數據塊的切分方式無關，等同 EqualFunc(cl, other, eq)
+func (cl *ChunkPipe[T]) Equal(other *ChunkPipe[T], eq func(a, b T) bool) bool {
+	if other == nil {
+		return false
+	}
+	return EqualFunc(cl, other, eq)
+}
+
+// Slice 返回邏輯索引 [start, end) 的元素副本，範圍無效時返回 nil
+func (cl *ChunkPipe[T]) Slice(start, end int) []T {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if start < 0 || end > cl.len() || start > end {
+		return nil
+	}
+	ret := make([]T, end-start)
+	cl.copyAt(ret, cl.offset+start)
+	return ret
+}
+
+// GetRange 將從邏輯索引 start 起的 count 個元素附加到 dst 後返回，
+// dst 容量足夠時不另外配置；範圍無效時原樣返回 dst 與 false
+func (cl *ChunkPipe[T]) GetRange(start, count int, dst []T) ([]T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if start < 0 || count < 0 || count > cl.len()-start {
+		return dst, false
+	}
+	n := len(dst)
+	dst = slices.Grow(dst, count)[:n+count]
+	cl.copyAt(dst[n:], cl.offset+start)
+	return dst, true
+}
+
+// CopyTo 從邏輯索引 offset 起將元素複製到 dst，返回複製的數量，
+// 最多複製 len(dst) 個；offset 超出範圍時返回 0
+func (cl *ChunkPipe[T]) CopyTo(dst []T, offset int) int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if offset < 0 {
+		return 0
+	}
+	return cl.copyAt(dst, cl.offset+offset)
+}
+
+// SubPipe 返回一個只包含邏輯索引 [start, end) 的新管道，範圍無效時返回 nil。
+// 新管道直接引用 cl 的區塊而不複製；與 CloneShared 相同，範圍內的區塊在兩邊都改為共用，
+// 任一方原地修改前才會複製，因此兩個管道之後的修改互不影響
+func (cl *ChunkPipe[T]) SubPipe(start, end int) *ChunkPipe[T] {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if start < 0 || end > cl.len() || start > end {
+		return nil
+	}
+	ret := cl.newLike()
+	i, pos, _ := cl.find(cl.offset + start)
+	for n := end - start; n > 0; i, pos = i+1, 0 {
+		cl.toHeap(i)
+		c := &cl.list[i]
+		// cl 的區塊也不再屬於自己，取空後不會放回池中被之後的推入覆寫
+		c.cap = 0
+		c.shared = true
+		val := c.val[pos:]
+		val = val[:min(n, len(val))]
+		ret.list = append(ret.list, offset[T]{val: val[:len(val):len(val)], shared: true})
+		n -= len(val)
+	}
+	ret.reindex(0)
+	return ret
+}
+
+// Append 將 other 的所有區塊依序連結到 cl 的尾端並清空 other，返回移動的元素數量。
+// 只搬移區塊而不複製元素，成本與 other 的區塊數量成正比。
+// cl 已關閉或超過上限時不移動任何元素並返回 0
+func (cl *ChunkPipe[T]) Append(other *ChunkPipe[T]) int {
+	if other == nil || other == cl {
+		return 0
+	}
+	lockPair(cl, other)
+	n := cl.moveFrom(other)
+	unlockMove(cl, other, n)
+	return n
+}
+
+// MoveTo 將 cl 的所有區塊移到 dst 的尾端，返回移動的元素數量，等同 dst.Append(cl)。
+// 適合在管線的各階段之間整批交接資料
+func (cl *ChunkPipe[T]) MoveTo(dst *ChunkPipe[T]) int {
+	return dst.Append(cl)
+}
+
+// Split 在邏輯索引 index 處切開管道：cl 保留 [0, index)，其餘元素移到返回的新管道。
+// 只會切分 index 所在的一個區塊，不複製任何元素；index 無效時返回 cl 與 nil
+func (cl *ChunkPipe[T]) Split(index int) (*ChunkPipe[T], *ChunkPipe[T]) {
+	cl.mu.Lock()
+	n := cl.len() - index
+	if index < 0 || n < 0 {
+		cl.mu.Unlock()
+		return cl, nil
+	}
+
+	i := cl.splitAt(cl.offset + index)
+	ret := cl.newLike()
+	// 區塊記錄的是絕對位置，新管道從切點起算即可沿用
+	ret.offset = cl.offset + index
+	ret.list = slices.Clone(cl.list[i:])
+	cl.giveExternal(ret, ret.list)
+	clear(cl.list[i:])
+	cl.list = cl.list[:i]
+	cl.unlock(0, n)
+	return cl, ret
+}
+
+// Partition 將所有元素依 pred 分到兩個新管道並清空 cl：滿足 pred 的元素在第一個管道，
+// 其餘在第二個，各自保持原本的順序。區塊中連續落在同一邊的元素直接沿用原本的記憶體，不複製
+func (cl *ChunkPipe[T]) Partition(pred func(T) bool) (*ChunkPipe[T], *ChunkPipe[T]) {
+	cl.mu.Lock()
+	yes, no := cl.newLike(), cl.newLike()
+	n := cl.len()
+	for i := range cl.list {
+		cl.toHeap(i)
+		c := cl.list[i]
+		a, side := 0, pred(c.val[0])
+		for b := 1; b <= len(c.val); b++ {
+			var next bool
+			if b < len(c.val) {
+				if next = pred(c.val[b]); next == side {
+					continue
+				}
+			}
+			run := offset[T]{val: c.val[a:b:b], shared: c.shared}
+			if c.cap > 0 {
+				run.cap = b - a
+			}
+			if a == 0 {
+				run.dead = c.dead
+			}
+			dst := no
+			if side {
+				dst = yes
+			}
+			run.off = dst.offset + dst.len() + len(run.val)
+			dst.list = append(dst.list, run)
+			a, side = b, next
+		}
+	}
+	clear(cl.list)
+	cl.list = cl.list[:0]
+	cl.offset += n
+	cl.unlock(0, n)
+	return yes, no
+}
+
+// moveFrom 將 src 的所有區塊連結到 cl 的尾端，呼叫者需持有兩者的寫鎖
+func (cl *ChunkPipe[T]) moveFrom(src *ChunkPipe[T]) int {
+	n := src.len()
+	if n == 0 || cl.closed || cl.maxLen > 0 && cl.len()+n > cl.maxLen {
+		return 0
+	}
+
+	wasEmpty := len(cl.list) == 0
+	i := len(cl.list)
+	cl.list = append(cl.list, src.list...)
+	cl.reindex(i)
+	src.giveExternal(cl, src.list)
+	clear(src.list)
+	src.list = src.list[:0]
+	src.offset += n
+	if wasEmpty {
+		cl.signal()
+	}
+	return n
+}
+
+// Clone 返回 cl 的深度複製，所有元素複製到新管道的單一自有數據塊中
+func (cl *ChunkPipe[T]) Clone() *ChunkPipe[T] {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	ret := cl.newLike()
+	if n := cl.len(); n > 0 {
+		val := make([]T, n)
+		cl.copyAt(val, cl.offset)
+		ret.pushOwned(val)
+	}
+	return ret
+}
+
+// CloneShared 返回與 cl 共用區塊的複製，建立時不複製任何元素。
+// 共用的區塊在任一方原地修改（例如 FillRange）前才會被複製，
+// 推入則一律寫入新的區塊，因此兩個管道之後的修改互不影響
+func (cl *ChunkPipe[T]) CloneShared() *ChunkPipe[T] {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	ret := cl.newLike()
+	ret.offset = cl.offset
+	ret.list = make([]offset[T], len(cl.list))
+	for i := range cl.list {
+		cl.toHeap(i)
+		c := &cl.list[i]
+		// 兩邊都改為不可原地寫入，避免追加或清除 slots 時覆寫對方的元素
+		c.cap = 0
+		c.shared = true
+		ret.list[i] = offset[T]{val: c.val[:len(c.val):len(c.val)], off: c.off, shared: true}
+	}
+	return ret
+}
+
+// unshare 在原地修改第 i 個區塊前，將共用的區塊複製為自有區塊，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) unshare(i int) {
+	c := &cl.list[i]
+	if !c.shared {
+		return
+	}
+	c.val = append(make([]T, 0, len(c.val)), c.val...)
+	c.cap = cap(c.val)
+	c.dead = 0
+	c.shared = false
+}
+
+// PeekChunkFront 返回頭部區塊的視圖但不彈出，處理完後以 Discard 前進。
+// 與 ChunkSlice 相同，視圖直接引用管道內部記憶體，只在下一次彈出或推入之前有效
+func (cl *ChunkPipe[T]) PeekChunkFront() ([]T, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	if len(cl.list) == 0 {
+		return nil, false
+	}
+	val := cl.list[0].val
+	return val[:len(val):len(val)], true
+}
+
+// Discard 從頭部丟棄最多 n 個元素，返回實際丟棄的數量，等同 DropFront
+func (cl *ChunkPipe[T]) Discard(n int) int {
+	return cl.DropFront(n)
+}
+
+// DropFront 從頭部丟棄最多 n 個元素，返回實際丟棄的數量。
+// 只前進偏移並整塊移除取空的區塊，不配置結果切片，適合跳過填充資料或快速丟棄過期的積壓
+func (cl *ChunkPipe[T]) DropFront(n int) int {
+	cl.mu.Lock()
+	n = max(min(n, cl.len()), 0)
+	cl.discardFront(n)
+	cl.unlock(0, n)
+	return n
+}
+
+// DropEnd 從尾端丟棄最多 n 個元素，返回實際丟棄的數量，與 DropFront 相同不配置結果切片
+func (cl *ChunkPipe[T]) DropEnd(n int) int {
+	cl.mu.Lock()
+	n = max(min(n, cl.len()), 0)
+	cl.discardEnd(n)
+	cl.unlock(0, n)
+	return n
+}
+
+// PeekN 返回頭部最多 n 個元素的副本，不會彈出任何元素
+func (cl *ChunkPipe[T]) PeekN(n int) []T {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	n = min(n, cl.len())
+	if n <= 0 {
+		return []T{}
+	}
+	ret := make([]T, n)
+	cl.copyAt(ret, cl.offset)
+	return ret
+}
+
+// copyAt 從絕對位置 target 起將元素複製到 dst，返回複製的數量，呼叫者需持有鎖
+func (cl *ChunkPipe[T]) copyAt(dst []T, target int) int {
+	i, pos, ok := cl.find(target)
+	if !ok {
+		return 0
+	}
+	n := 0
+	for ; i < len(cl.list) && n < len(dst); i, pos = i+1, 0 {
+		n += copy(dst[n:], cl.list[i].val[pos:])
+	}
+	return n
+}
+
+// Windows 返回依序產生固定寬度視窗的迭代器，每個視窗包含 size 個元素，
+// 起點每次前進 step；剩餘元素不足 size 時停止。
+// 每個視窗都是新配置的副本，產生視窗之間不持有鎖
+func (cl *ChunkPipe[T]) Windows(size, step int) iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		if size <= 0 || step <= 0 {
+			return
+		}
+
+		cl.mu.RLock()
+		start := cl.offset
+		cl.mu.RUnlock()
+
+		for ; ; start += step {
+			window := make([]T, size)
+			cl.mu.RLock()
+			n := cl.copyAt(window, start)
+			cl.mu.RUnlock()
+			if n < size || !yield(window) {
+				return
+			}
+		}
+	}
+}
+
+// ChunkAt 返回包含指定索引的區塊視圖，以及該元素在視圖中的位置。
+// 與 ChunkSlice 相同，視圖直接引用管道內部記憶體，只在下一次彈出或推入之前有效；
+// 需要長期保存時請自行複製
+func (cl *ChunkPipe[T]) ChunkAt(index int) ([]T, int, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	i, pos, ok := cl.locate(index)
+	if !ok {
+		return nil, 0, false
+	}
+	val := cl.list[i].val
+	return val[:len(val):len(val)], pos, true
+}
+
+// FillRange 將邏輯索引 [start, end) 的元素原地覆寫為 v，範圍無效時返回 false。
+// 借用呼叫者記憶體的區塊也會被直接寫入
+func (cl *ChunkPipe[T]) FillRange(start, end int, v T) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if start < 0 || end > cl.len() || start > end {
+		return false
+	}
+	if start == end {
+		return true
+	}
+
+	i, pos, _ := cl.locate(start)
+	for n := end - start; n > 0; i, pos = i+1, 0 {
+		cl.unshare(i)
+		val := cl.list[i].val[pos:]
+		if len(val) > n {
+			val = val[:n]
+		}
+		for j := range val {
+			val[j] = v
+		}
+		n -= len(val)
+	}
+	return true
+}
+
+// Swap 交換邏輯索引 i 與 j 的元素；任一索引無效時返回 false
+func (cl *ChunkPipe[T]) Swap(i, j int) bool {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	ci, pi, ok := cl.locate(i)
+	if !ok {
+		return false
+	}
+	cj, pj, ok := cl.locate(j)
+	if !ok {
+		return false
+	}
+	cl.unshare(ci)
+	cl.unshare(cj)
+	a, b := &cl.list[ci].val[pi], &cl.list[cj].val[pj]
+	*a, *b = *b, *a
+	return true
+}
+
+// Reverse 原地反轉所有元素的順序：反轉區塊列表與每個區塊的內容後重建偏移。
+// 與 FillRange 相同，借用呼叫者記憶體的區塊也會被直接反轉
+func (cl *ChunkPipe[T]) Reverse() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	slices.Reverse(cl.list)
+	for i := range cl.list {
+		cl.unshare(i)
+		slices.Reverse(cl.list[i].val)
+	}
+	cl.reindex(0)
+}
+
+// Sort 依 less 穩定排序所有元素。先分別排序每個區塊，再以 k 路合併寫入新的區塊，
+// 暫存空間以區塊為單位配置，不需要一次配置與總長度相同的切片
+func (cl *ChunkPipe[T]) Sort(less func(a, b T) bool) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.sort(func(s []T) {
+		slices.SortStableFunc(s, func(a, b T) int {
+			if less(a, b) {
+				return -1
+			}
+			if less(b, a) {
+				return 1
+			}
+			return 0
+		})
+	}, less)
+}
+
+// IsSorted 回報所有元素是否已依 less 排序
+func (cl *ChunkPipe[T]) IsSorted(less func(a, b T) bool) bool {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	var prev T
+	for i := range cl.list {
+		for j, v := range cl.list[i].val {
+			if (i > 0 || j > 0) && less(v, prev) {
+				return false
+			}
+			prev = v
+		}
+	}
+	return true
+}
+
+// BinarySearch 在已依 cmp 排序的管道中搜尋 target，返回找到的位置，
+// 或 target 應插入的位置與 false。cmp(e, target) 的回傳值語意與 slices.BinarySearchFunc 相同。
+// 先以各區塊的最後一個元素二分搜尋區塊，再於區塊內二分搜尋，為 O(log n)
+func (cl *ChunkPipe[T]) BinarySearch(target T, cmp func(a, b T) int) (int, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	i, _ := slices.BinarySearchFunc(cl.list, target, func(c offset[T], target T) int {
+		if cmp(c.val[len(c.val)-1], target) < 0 {
+			return -1
+		}
+		return 1
+	})
+	if i == len(cl.list) {
+		return cl.len(), false
+	}
+	c := cl.list[i]
+	pos, found := slices.BinarySearchFunc(c.val, target, cmp)
+	return c.off - len(c.val) - cl.offset + pos, found
+}
+
+// sort 以 sortChunk 排序每個區塊後依 less 合併，相等時取較前面的區塊以保持穩定，
+// 呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) sort(sortChunk func([]T), less func(a, b T) bool) {
+	for i := range cl.list {
+		// 借用與共用的區塊不能原地排序，先複製為自有區塊
+		if c := &cl.list[i]; c.cap == 0 {
+			c.val = slices.Clone(c.val)
+			c.cap = cap(c.val)
+			c.shared = false
+		}
+		sortChunk(cl.list[i].val)
+	}
+	if len(cl.list) < 2 {
+		return
+	}
+
+	// runs 是以各區塊剩餘元素組成的最小堆積
+	runs := make([][]T, len(cl.list))
+	order := make([]int, len(cl.list))
+	for i := range cl.list {
+		runs[i] = cl.list[i].val
+		order[i] = i
+	}
+	before := func(a, b int) bool {
+		x, y := runs[order[a]][0], runs[order[b]][0]
+		return less(x, y) || !less(y, x) && order[a] < order[b]
+	}
+	down := func(i int) {
+		for {
+			m := i
+			if l := 2*i + 1; l < len(order) && before(l, m) {
+				m = l
+			}
+			if r := 2*i + 2; r < len(order) && before(r, m) {
+				m = r
+			}
+			if m == i {
+				return
+			}
+			order[i], order[m] = order[m], order[i]
+			i = m
+		}
+	}
+	for i := len(order)/2 - 1; i >= 0; i-- {
+		down(i)
+	}
+
+	size := cl.chunkCap()
+	remain := cl.len()
+	list := make([]offset[T], 0, (remain+size-1)/size)
+	var val []T
+	for len(order) > 0 {
+		if val == nil {
+			val = make([]T, 0, min(size, remain))
+		}
+		run := runs[order[0]]
+		val = append(val, run[0])
+		if run = run[1:]; len(run) > 0 {
+			runs[order[0]] = run
+		} else {
+			order[0] = order[len(order)-1]
+			order = order[:len(order)-1]
+		}
+		down(0)
+
+		if len(val) == cap(val) {
+			remain -= len(val)
+			list = append(list, offset[T]{val: val, cap: cap(val)})
+			val = nil
+		}
+	}
+
+	for _, c := range cl.list {
+		cl.clearSlots(c, c.val)
+		cl.retire(c)
+	}
+	cl.list = list
+	cl.reindex(0)
+}
+
+// Filter 只保留滿足 keep 的元素，返回移除的數量。
+// 自有區塊原地壓縮，有元素被移除的借用區塊會複製成新的自有區塊
+func (cl *ChunkPipe[T]) Filter(keep func(T) bool) int {
+	cl.mu.Lock()
+	removed := cl.filter(keep)
+	cl.unlock(0, removed)
+	return removed
+}
+
+// Dedup 將連續相等的元素只保留第一個，跨區塊邊界同樣適用，返回移除的數量。
+// 每個元素與最後一個保留的元素比較，而非原本的前一個元素；
+// eq 不具遞移性時結果可能與 slices.CompactFunc 不同
+func (cl *ChunkPipe[T]) Dedup(eq func(a, b T) bool) int {
+	var last T
+	first := true
+	return cl.Filter(func(v T) bool {
+		if !first && eq(last, v) {
+			return false
+		}
+		last, first = v, false
+		return true
+	})
+}
+
+// filter 對每個元素呼叫一次 keep 並移除不保留的元素，返回移除的數量，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) filter(keep func(T) bool) int {
+	removed := 0
+	list := cl.list[:0]
+	shift := stampShift{stamps: cl.stamps}
+	for _, c := range cl.list {
+		n, start := len(c.val), c.off-len(c.val)
+		if first := slices.IndexFunc(c.val, func(x T) bool { return !keep(x) }); first >= 0 {
+			shift.remove(start + first)
+			var kept []T
+			if c.cap > 0 {
+				kept = c.val[:first]
+			} else {
+				kept = append(make([]T, 0, n), c.val[:first]...)
+			}
+			for j, x := range c.val[first+1:] {
+				if keep(x) {
+					kept = append(kept, x)
+				} else {
+					shift.remove(start + first + 1 + j)
+				}
+			}
+			if c.cap > 0 {
+				cl.clearSlots(c, c.val[len(kept):])
+				c.val = kept
+			} else {
+				c = offset[T]{val: kept, cap: cap(kept)}
+			}
+		}
+		removed += n - len(c.val)
+		if len(c.val) > 0 {
+			list = append(list, c)
+		}
+	}
+	shift.done()
+	clear(cl.list[len(list):])
+	cl.list = list
+	cl.reindex(0)
+	return removed
+}
+
+// Apply 以 fn 的返回值原地取代每個元素。
+// 與 FillRange 相同，借用呼叫者記憶體的區塊也會被直接寫入
+func (cl *ChunkPipe[T]) Apply(fn func(T) T) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	for i := range cl.list {
+		cl.unshare(i)
+		val := cl.list[i].val
+		for j := range val {
+			val[j] = fn(val[j])
+		}
+	}
+}
+
+// Replace 將邏輯索引 [start, end) 的元素替換為 data 的副本，
+// data 的長度可以與被替換的範圍不同；範圍無效時返回 false
+func (cl *ChunkPipe[T]) Replace(start, end int, data []T) bool {
+	cl.mu.Lock()
+	if start < 0 || end > cl.len() || start > end {
+		cl.mu.Unlock()
+		return false
 	}
 
-	off := cl.offset
-	if len(cl.list) != 0 {
-		off = cl.list[len(cl.list)-1].off
+	wasEmpty := len(cl.list) == 0
+	if end < cl.len() {
+		cl.untimed += len(data)
 	}
+	cl.shiftStamps(cl.offset+start, end-start, len(data))
+	i := cl.splitAt(cl.offset + start)
+	j := cl.splitAt(cl.offset + end)
 
-	cl.list = append(cl.list, offset[T]{
-		val: data,
-		off: off + len(data),
-	})
+	var chunks []offset[T]
+	if len(data) > 0 {
+		val := append([]T(nil), data...)
+		chunks = append(chunks, offset[T]{val: val, cap: cap(val)})
+	}
+	for _, c := range cl.list[i:j] {
+		cl.clearSlots(c, c.val)
+	}
+	cl.list = slices.Replace(cl.list, i, j, chunks...)
+	cl.reindex(i)
 
-	return cl
+	if wasEmpty && len(data) > 0 {
+		cl.signal()
+	}
+	cl.unlock(len(data), end-start)
+	return true
 }
 
-func (cl *ChunkPipe[T]) Get(index int) (T, bool) {
-	cl.mu.RLock()
-	defer cl.mu.RUnlock()
+// Insert 在邏輯索引 index 之前插入 data 的副本，index 等於長度時加到尾端。
+// 所在的區塊會被切分，索引無效時返回 false
+func (cl *ChunkPipe[T]) Insert(index int, data []T) bool {
+	return cl.Replace(index, index, data)
+}
 
-	var zero T
-	if len(cl.list) == 0 || index < 0 {
+// RemoveAt 移除並返回邏輯索引 index 的元素
+func (cl *ChunkPipe[T]) RemoveAt(index int) (T, bool) {
+	cl.mu.Lock()
+	i, pos, ok := cl.locate(index)
+	if !ok {
+		cl.mu.Unlock()
+		var zero T
 		return zero, false
 	}
+	v := cl.list[i].val[pos]
+	cl.removeAt(i, pos)
+	cl.unlock(0, 1)
+	return v, true
+}
 
-	target := index + cl.offset
-	l := 0
-	r := len(cl.list) - 1
+// removeAt 移除第 i 個區塊中位置 pos 的元素，呼叫者需持有寫鎖。
+// 自有區塊原地前移後續元素，借用區塊不能修改，改為切分後移除
+func (cl *ChunkPipe[T]) removeAt(i, pos int) {
+	c := &cl.list[i]
+	cl.shiftStamps(c.off-len(c.val)+pos, 1, 0)
+	if c.cap == 0 {
+		target := c.off - len(c.val) + pos
+		i = cl.splitAt(target)
+		cl.splitAt(target + 1)
+		cl.list = slices.Delete(cl.list, i, i+1)
+		cl.reindex(i)
+		return
+	}
 
-	if target >= cl.list[r].off {
-		return zero, false
+	orig := *c
+	copy(c.val[pos:], c.val[pos+1:])
+	cl.clearSlots(*c, c.val[len(c.val)-1:])
+	c.val = c.val[:len(c.val)-1]
+	if len(c.val) == 0 {
+		cl.list = slices.Delete(cl.list, i, i+1)
+		cl.retire(orig)
 	}
+	cl.reindex(i)
+}
 
-	if cl.list[l].off > target {
-		off := cl.list[0]
-		val := off.val
-		target = len(val) - (off.off - target)
-		return val[target], true
+// splitAt 確保絕對位置 target 處是區塊邊界，返回從 target 開始的區塊索引；
+// target 為尾端時返回 len(list)。呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) splitAt(target int) int {
+	i, pos, ok := cl.find(target)
+	if !ok {
+		return len(cl.list)
+	}
+	if pos == 0 {
+		return i
 	}
 
-	for r-l > 1 {
-		m := (r + l) >> 1
-		if cl.list[m].off > target {
-			r = m
-		} else {
-			l = m
-		}
+	cl.toHeap(i)
+	c := cl.list[i]
+	// 左半部限制容量，避免成為尾端後原地追加覆寫右半部的記憶體
+	left := offset[T]{val: c.val[:pos:pos], off: c.off - len(c.val) + pos, dead: c.dead, shared: c.shared}
+	right := offset[T]{val: c.val[pos:], off: c.off, shared: c.shared}
+	if c.cap > 0 {
+		left.cap = cap(left.val)
+		right.cap = cap(right.val)
 	}
+	cl.list[i] = left
+	cl.list = slices.Insert(cl.list, i+1, right)
+	return i + 1
+}
 
-	off := cl.list[r]
-	val := off.val
-	target = len(val) - (off.off - target)
-	return val[target], true
+// reindex 從第 i 個區塊開始重新計算累計偏移，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) reindex(i int) {
+	off := cl.offset
+	if i > 0 {
+		off = cl.list[i-1].off
+	}
+	for ; i < len(cl.list); i++ {
+		off += len(cl.list[i].val)
+		cl.list[i].off = off
+	}
 }
 
 // 從頭部彈出數據
 func (cl *ChunkPipe[T]) PopChunkFront() ([]T, bool) {
 	cl.mu.Lock()
-	defer cl.mu.Unlock()
+	ret, ok := cl.popChunkFront()
+	cl.unlock(0, len(ret))
+	return ret, ok
+}
 
+func (cl *ChunkPipe[T]) popChunkFront() ([]T, bool) {
 	if len(cl.list) > 0 {
-		cl.offset = cl.list[0].off
-		ret := cl.list[0].val
+		c := cl.list[0]
+		cl.offset = c.off
+		ret := c.val[:len(c.val):len(c.val)]
 		cl.list = cl.list[1:]
-		return ret, true
+		return cl.handOut(c, ret), true
 	}
 	return nil, false
 }
 
+// PopChunkFrontPooled 從頭部彈出數據塊，並返回一個歸還函式。
+// 呼叫歸還函式後，管道自有區塊的底層陣列會回收供之後的 Push 重複使用，
+// 因此呼叫後不可再存取返回的切片；不呼叫歸還函式則由 GC 正常回收
+func (cl *ChunkPipe[T]) PopChunkFrontPooled() ([]T, func(), bool) {
+	cl.mu.Lock()
+	var buf []T
+	if len(cl.list) > 0 && cl.list[0].cap > 0 && cl.allocator == nil {
+		buf = cl.list[0].val
+	}
+	ret, ok := cl.popChunkFront()
+	cl.unlock(0, len(ret))
+
+	release := func() {
+		if buf == nil {
+			return
+		}
+		// 區塊可能仍被 WriteTo 等在鎖外使用的視圖引用，交由 reuse 在加鎖後判斷
+		cl.mu.Lock()
+		if cl.pointers {
+			clear(buf[:cap(buf)])
+		}
+		cl.reuse(buf[:0])
+		cl.mu.Unlock()
+		buf = nil
+	}
+	return ret, release, ok
+}
+
+// recycle 清空自有區塊的底層陣列並放回池中，供之後的推入重複使用
+func (cl *ChunkPipe[T]) recycle(val []T) {
+	val = val[:cap(val)]
+	clear(val)
+	val = val[:0]
+	cl.pool.Put(&val)
+}
+
+// handOut 返回要交給呼叫者的區塊內容 ret；由 Allocator 配置的區塊之後會被釋放，
+// 因此先複製到堆積上再放回空閒列表，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) handOut(c offset[T], ret []T) []T {
+	if !cl.isExternal(c) {
+		return ret
+	}
+	ret = slices.Clone(ret)
+	cl.retire(c)
+	return ret
+}
+
+// retire 將取空後移出 list 的區塊 c 整塊交給 reuse，呼叫者需持有寫鎖。
+// c 為取空前的狀態；移出的 slots 已由 clearSlots 清除，不需要再次清除。
+// 借用與共用的區塊不屬於管道，直接略過
+func (cl *ChunkPipe[T]) retire(c offset[T]) {
+	if c.cap == 0 {
+		return
+	}
+	cl.reuse(chunkBase(c))
+}
+
+// reuse 將不再使用的自有陣列 val 放回空閒列表，列表已滿時放回池中或交還 Allocator，
+// 讓之後的推入重複使用，呼叫者需持有寫鎖。
+// 有 pin 住的視圖時先暫存，避免視圖讀到之後推入的資料；管道關閉後外部配置的陣列直接歸還
+func (cl *ChunkPipe[T]) reuse(val []T) {
+	if cl.pinned > 0 {
+		cl.parked = append(cl.parked, val)
+		return
+	}
+	// 關閉後不再推入，外部配置的陣列留在空閒列表中就不會再被歸還
+	if cl.closed && cl.freeExternal(val) {
+		return
+	}
+	if len(cl.free) < freeListSize {
+		cl.free = append(cl.free, val)
+		return
+	}
+	if !cl.freeExternal(val) {
+		cl.recycle(val)
+	}
+}
+
+// pin 在釋放鎖後繼續使用內部區塊的視圖之前呼叫，期間取空的區塊不會被重複使用或歸還，
+// 呼叫者需持有寫鎖，用完後以 unpin 結束
+func (cl *ChunkPipe[T]) pin() {
+	cl.pinned++
+}
+
+// unpin 結束一個 pin 住的視圖，最後一個視圖結束時處理期間暫存的區塊，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) unpin() {
+	cl.pinned--
+	if cl.pinned > 0 {
+		return
+	}
+	parked := cl.parked
+	cl.parked = nil
+	for _, val := range parked {
+		cl.reuse(val)
+	}
+}
+
+// Clear 移除所有元素，管道可以繼續使用。自有區塊的底層陣列會放回池中，
+// 之後的推入會優先重複使用，不需重新配置
+func (cl *ChunkPipe[T]) Clear() {
+	cl.mu.Lock()
+	n := cl.len()
+	cl.evict(cl.offset, n)
+	for _, c := range cl.list {
+		cl.clearSlots(c, c.val)
+		cl.retire(c)
+	}
+	cl.releaseFree()
+	clear(cl.list)
+	cl.list = cl.list[:0]
+	cl.stamps = nil
+	// 絕對位置持續遞增，走訪中的 RangeValues 與 Cursor 不會重複看到之後推入的元素
+	cl.offset += n
+	cl.emit(OpClear, n)
+	h := cl.release(0, n)
+	cl.mu.Unlock()
+	h.run(0, n)
+}
+
+// Drain 以一次加鎖取出目前所有元素，再依序對每個元素呼叫 fn。
+// fn 在鎖外執行，可以操作管道；呼叫期間新推入的元素不會被取出。
+// 走訪完的自有區塊會放回池中重複使用
+func (cl *ChunkPipe[T]) Drain(fn func(T)) {
+	cl.mu.Lock()
+	list := cl.list
+	n := cl.len()
+	cl.list = nil
+	cl.offset += n
+	cl.unlock(0, n)
+
+	for _, c := range list {
+		for _, v := range c.val {
+			fn(v)
+		}
+	}
+	cl.mu.Lock()
+	for _, c := range list {
+		cl.clearSlots(c, c.val)
+		cl.retire(c)
+	}
+	cl.mu.Unlock()
+}
+
+// PopAll 取出並返回所有元素，管道被清空
+func (cl *ChunkPipe[T]) PopAll() []T {
+	cl.mu.Lock()
+	n := cl.len()
+	ret := make([]T, n)
+	cl.copyAt(ret, cl.offset)
+	cl.discardFront(n)
+	cl.unlock(0, n)
+	return ret
+}
+
 // 從尾部彈出數據
 func (cl *ChunkPipe[T]) PopChunkEnd() ([]T, bool) {
 	cl.mu.Lock()
-	defer cl.mu.Unlock()
+	ret, ok := cl.popChunkEnd()
+	cl.unlock(0, len(ret))
+	return ret, ok
+}
 
+func (cl *ChunkPipe[T]) popChunkEnd() ([]T, bool) {
 	if len(cl.list) > 0 {
-		ret := cl.list[len(cl.list)-1].val
+		c := cl.list[len(cl.list)-1]
+		ret := c.val[:len(c.val):len(c.val)]
 		cl.list = cl.list[:len(cl.list)-1]
-		return ret, true
+		return cl.handOut(c, ret), true
 	}
 	return nil, false
 }
 
 func (cl *ChunkPipe[T]) PopFront() (T, bool) {
 	cl.mu.Lock()
-	defer cl.mu.Unlock()
+	ret, ok := cl.popFront()
+	popped := 0
+	if ok {
+		popped = 1
+	}
+	cl.unlock(0, popped)
+	return ret, ok
+}
 
+func (cl *ChunkPipe[T]) popFront() (T, bool) {
 	if len(cl.list) > 0 {
-		val := cl.list[0].val
+		head := cl.list[0]
+		val := head.val
 		ret := val[0]
+		cl.clearSlots(head, val[:1])
 		val = val[1:]
 		cl.list[0].val = val
+		if cl.list[0].cap > 0 {
+			cl.list[0].cap--
+		}
+		cl.list[0].dead++
 		cl.offset++
 		if len(val) == 0 {
 			cl.list = cl.list[1:]
+			cl.retire(head)
+		} else {
+			cl.trimHead()
 		}
 		return ret, true
 	}
@@ -107,14 +1744,106 @@ func (cl *ChunkPipe[T]) PopFront() (T, bool) {
 	return ret, false
 }
 
+// discardFront 從頭部丟棄最多 n 個元素，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) discardFront(n int) {
+	for n > 0 && len(cl.list) > 0 {
+		head := &cl.list[0]
+		orig := *head
+		k := min(n, len(head.val))
+		cl.clearSlots(*head, head.val[:k])
+		head.val = head.val[k:]
+		if head.cap > 0 {
+			head.cap -= k
+		}
+		head.dead += k
+		cl.offset += k
+		n -= k
+		if len(head.val) == 0 {
+			cl.list = cl.list[1:]
+			cl.retire(orig)
+		} else {
+			cl.trimHead()
+		}
+	}
+}
+
+// trimHead 在頭部區塊已彈出的前綴過大時，將剩餘元素複製到新的自有陣列，
+// 讓舊的底層陣列可以被回收。不超過區塊大小的自有區塊取空後會放回空閒列表，
+// 不值得為它重新配置，因此略過。呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) trimHead() {
+	head := &cl.list[0]
+	if cl.trimRatio <= 0 || head.dead < trimMinDead {
+		return
+	}
+	if head.cap > 0 && head.dead+head.cap <= cl.chunkSize {
+		return
+	}
+	cl.shrinkHead(cl.trimRatio)
+}
+
+// shrinkHead 在頭部區塊已彈出的前綴達到剩餘元素的 ratio 倍時重新配置頭部區塊，
+// 返回釋放的已彈出元素數量，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) shrinkHead(ratio float64) int {
+	if len(cl.list) == 0 {
+		return 0
+	}
+	head := &cl.list[0]
+	if head.dead == 0 || float64(head.dead) < ratio*float64(len(head.val)) {
+		return 0
+	}
+	old := *head
+	head.val = append([]T(nil), head.val...)
+	cl.freeChunk(old)
+	head.cap = cap(head.val)
+	head.dead = 0
+	head.shared = false
+	return old.dead
+}
+
+// TrimFront 在頭部區塊已彈出的前綴達到剩餘元素的 WithTrimRatio 倍時，
+// 立即將剩餘元素複製到新的陣列並放掉舊的陣列，返回釋放的已彈出元素數量。
+// PopFront 只在前綴夠大時才會自動這麼做；比例設為 0 時只要有已彈出的前綴就重新配置
+func (cl *ChunkPipe[T]) TrimFront() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return cl.shrinkHead(max(cl.trimRatio, 0))
+}
+
+// Shrink 釋放管道保留但沒有存放元素的記憶體：重新配置有已彈出前綴的頭部區塊，
+// 並放掉空閒列表與預先分配的區塊，返回釋放的元素容量。適合在流量高峰過後呼叫
+func (cl *ChunkPipe[T]) Shrink() int {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	n := cl.shrinkHead(0) + cap(cl.spare)
+	for _, val := range cl.free {
+		n += cap(val)
+	}
+	cl.releaseFree()
+	cl.free = nil
+	cl.spare = nil
+	return n
+}
+
 // 從尾部彈出數據
 func (cl *ChunkPipe[T]) PopEnd() (T, bool) {
 	cl.mu.Lock()
-	defer cl.mu.Unlock()
+	ret, ok := cl.popEnd()
+	popped := 0
+	if ok {
+		popped = 1
+	}
+	cl.unlock(0, popped)
+	return ret, ok
+}
 
+func (cl *ChunkPipe[T]) popEnd() (T, bool) {
 	if len(cl.list) > 0 {
-		val := cl.list[len(cl.list)-1].val
+		tail := cl.list[len(cl.list)-1]
+		val := tail.val
 		ret := val[len(val)-1]
+		cl.clearSlots(tail, val[len(val)-1:])
 		val = val[:len(val)-1]
 		cl.list[len(cl.list)-1].val = val
 		cl.list[len(cl.list)-1].off--
@@ -122,6 +1851,7 @@ func (cl *ChunkPipe[T]) PopEnd() (T, bool) {
 		if len(val) == 0 {
 			// remove the element
 			cl.list = cl.list[:len(cl.list)-1]
+			cl.retire(tail)
 		}
 		return ret, true
 	}
@@ -129,6 +1859,176 @@ func (cl *ChunkPipe[T]) PopEnd() (T, bool) {
 	return ret, false
 }
 
+// discardEnd 從尾端丟棄最多 n 個元素，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) discardEnd(n int) {
+	for n > 0 && len(cl.list) > 0 {
+		tail := cl.tail()
+		orig := *tail
+		k := min(n, len(tail.val))
+		cl.clearSlots(*tail, tail.val[len(tail.val)-k:])
+		tail.val = tail.val[:len(tail.val)-k]
+		tail.off -= k
+		n -= k
+		if len(tail.val) == 0 {
+			cl.list = cl.list[:len(cl.list)-1]
+			cl.retire(orig)
+		}
+	}
+}
+
+// PopFrontN 從頭部彈出恰好 n 個元素，可以跨越多個區塊，未取完的區塊留在原處。
+// 元素不足 n 個時不彈出任何元素並返回 false
+func (cl *ChunkPipe[T]) PopFrontN(n int) ([]T, bool) {
+	cl.mu.Lock()
+	if n < 0 || cl.len() < n {
+		cl.mu.Unlock()
+		return nil, false
+	}
+	ret := make([]T, n)
+	cl.copyAt(ret, cl.offset)
+	cl.discardFront(n)
+	cl.unlock(0, n)
+	return ret, true
+}
+
+// PopFrontInto 從頭部彈出最多 len(dst) 個元素並複製到 dst，返回彈出的數量。
+// 呼叫者可以重複使用 dst，不需要每次配置新的切片
+func (cl *ChunkPipe[T]) PopFrontInto(dst []T) int {
+	cl.mu.Lock()
+	n := cl.copyAt(dst, cl.offset)
+	cl.discardFront(n)
+	cl.unlock(0, n)
+	return n
+}
+
+// PopEndN 從尾端彈出恰好 n 個元素，返回的切片維持原本的順序。
+// 元素不足 n 個時不彈出任何元素並返回 false
+func (cl *ChunkPipe[T]) PopEndN(n int) ([]T, bool) {
+	cl.mu.Lock()
+	if n < 0 || cl.len() < n {
+		cl.mu.Unlock()
+		return nil, false
+	}
+	ret := make([]T, n)
+	cl.copyAt(ret, cl.offset+cl.len()-n)
+	cl.discardEnd(n)
+	cl.unlock(0, n)
+	return ret, true
+}
+
+// Repartition 將所有元素重新複製到容量約為 targetSize 的自有區塊中，
+// 釋放舊的底層陣列；targetSize 小於等於 0 時不做任何事
+func (cl *ChunkPipe[T]) Repartition(targetSize int) {
+	if targetSize <= 0 {
+		return
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.repartition(targetSize)
+}
+
+// Flatten 將所有元素合併為單一數據塊，之後 ChunkAt 等區塊視圖即可看到完整且連續的內容
+func (cl *ChunkPipe[T]) Flatten() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if len(cl.list) > 1 {
+		cl.repartition(cl.len())
+	}
+}
+
+// Compact 將相鄰、合計不超過區塊大小的數據塊合併為一塊，
+// 減少大量小量推入、部分彈出或 Insert、RemoveAt 切分後累積的細碎區塊。
+// 已經夠大的區塊保持不動、不複製，因此成本與需要合併的元素數量成正比
+func (cl *ChunkPipe[T]) Compact() {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.compact()
+}
+
+// compact 合併相鄰的小區塊，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) compact() {
+	size := cl.chunkCap()
+	// 合併後的區塊數量不會超過已讀取的數量，可以原地寫回 list
+	list := cl.list[:0]
+	for i := 0; i < len(cl.list); {
+		j, n := i, 0
+		for j < len(cl.list) && n+len(cl.list[j].val) <= size {
+			n += len(cl.list[j].val)
+			j++
+		}
+		if j-i < 2 {
+			list = append(list, cl.list[i])
+			i++
+			continue
+		}
+
+		val := cl.allocChunk(n)
+		for _, c := range cl.list[i:j] {
+			val = append(val, c.val...)
+		}
+		off := cl.list[j-1].off
+		for _, c := range cl.list[i:j] {
+			cl.clearSlots(c, c.val)
+			cl.retire(c)
+		}
+		list = append(list, offset[T]{val: val, off: off, cap: cap(val)})
+		i = j
+	}
+	clear(cl.list[len(list):])
+	cl.list = list
+}
+
+// autoCompact 在區塊的平均填充率低於 WithAutoCompact 的門檻時合併小區塊，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) autoCompact() {
+	if cl.compactFill <= 0 || len(cl.list) < minCompactChunks {
+		return
+	}
+	if float64(cl.len()) < cl.compactFill*float64(len(cl.list)*cl.chunkCap()) {
+		cl.compact()
+	}
+}
+
+// repartition 依 targetSize 重建區塊列表，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) repartition(targetSize int) {
+	remain := cl.len()
+	if remain == 0 {
+		return
+	}
+
+	list := make([]offset[T], 0, (remain+targetSize-1)/targetSize)
+	off := cl.offset
+	var val []T
+	for i := range cl.list {
+		src := cl.list[i].val
+		for len(src) > 0 {
+			if val == nil {
+				val = make([]T, 0, min(targetSize, remain))
+			}
+			n := copy(val[len(val):cap(val)], src)
+			val = val[:len(val)+n]
+			src = src[n:]
+			if len(val) == cap(val) {
+				off += len(val)
+				remain -= len(val)
+				list = append(list, offset[T]{
+					val: val,
+					off: off,
+					cap: cap(val),
+				})
+				val = nil
+			}
+		}
+	}
+	for _, c := range cl.list {
+		cl.freeChunk(c)
+	}
+	cl.list = list
+}
+
 // ValueSlice 返回所有值的切片
 func (cl *ChunkPipe[T]) ValueSlice() []T {
 	cl.mu.RLock()
@@ -150,7 +2050,10 @@ func (cl *ChunkPipe[T]) ValueSlice() []T {
 	return ret
 }
 
-// ChunkSlice 返回所有數據塊的切片
+// ChunkSlice 返回所有數據塊的切片。
+// 每個數據塊都直接引用管道內部記憶體，只在下一次彈出或推入之前有效：
+// 取空的區塊會被之後的推入重複使用，保留的切片可能出現新的資料，
+// FillRange 等原地修改也會反映在上面；需要長期保存時請使用 ChunkSliceCopy
 func (cl *ChunkPipe[T]) ChunkSlice() [][]T {
 	cl.mu.RLock()
 	defer cl.mu.RUnlock()
@@ -161,21 +2064,247 @@ func (cl *ChunkPipe[T]) ChunkSlice() [][]T {
 	ret := make([][]T, len(cl.list))
 
 	for i := range ret {
-		ret[i] = cl.list[i].val
+		val := cl.list[i].val
+		// 限制容量，避免呼叫者 append 時覆寫管道自有區塊的剩餘空間
+		ret[i] = val[:len(val):len(val)]
+	}
+	return ret
+}
+
+// NumChunks 返回目前的數據塊數量
+func (cl *ChunkPipe[T]) NumChunks() int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	return len(cl.list)
+}
+
+// ChunkLens 依序返回每個數據塊目前的有效長度
+func (cl *ChunkPipe[T]) ChunkLens() []int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	ret := make([]int, len(cl.list))
+	for i := range cl.list {
+		ret[i] = len(cl.list[i].val)
+	}
+	return ret
+}
+
+// ChunkSliceCopy 返回所有數據塊的副本，呼叫者可以安全地保存與修改
+func (cl *ChunkPipe[T]) ChunkSliceCopy() [][]T {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	ret := make([][]T, len(cl.list))
+	for i := range ret {
+		ret[i] = append([]T(nil), cl.list[i].val...)
 	}
 	return ret
 }
 
-func (cl *ChunkPipe[T]) size() int {
+// Len 返回目前的元素數量
+func (cl *ChunkPipe[T]) Len() int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	return cl.len()
+}
+
+// Cap 返回各數據塊底層陣列已配置的容量總和，與 Stats().AllocatedElements 相同
+func (cl *ChunkPipe[T]) Cap() int {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	return cl.allocated()
+}
+
+// IsEmpty 返回管道是否沒有任何元素
+func (cl *ChunkPipe[T]) IsEmpty() bool {
 	cl.mu.RLock()
 	defer cl.mu.RUnlock()
 
+	return len(cl.list) == 0
+}
+
+// len 返回元素數量，呼叫者需持有鎖
+func (cl *ChunkPipe[T]) len() int {
 	if len(cl.list) == 0 {
 		return 0
 	}
 	return cl.list[len(cl.list)-1].off - cl.offset
 }
 
+// RangeValues 依序對每個元素呼叫 fn，fn 返回 false 時停止，等同 RangeValuesN(defaultRangeBatch, fn)
+func (cl *ChunkPipe[T]) RangeValues(fn func(T) bool) {
+	cl.RangeValuesN(defaultRangeBatch, fn)
+}
+
+// RangeValuesN 依序對每個元素呼叫 fn，fn 返回 false 時停止。
+// 每次取得讀鎖時最多複製 batch 個元素到暫存區，fn 在鎖外執行，
+// 因此 fn 可以修改管道；走訪期間被彈出的元素會被略過
+func (cl *ChunkPipe[T]) RangeValuesN(batch int, fn func(T) bool) {
+	if batch <= 0 {
+		batch = defaultRangeBatch
+	}
+
+	buf := make([]T, batch)
+	pos := 0
+	for {
+		cl.mu.RLock()
+		pos = max(pos, cl.offset)
+		n := cl.copyAt(buf, pos)
+		cl.mu.RUnlock()
+
+		for _, v := range buf[:n] {
+			if !fn(v) {
+				return
+			}
+		}
+		if n < batch {
+			return
+		}
+		pos += n
+	}
+}
+
+// Values 返回依序走訪所有元素的迭代器，語意與 RangeValues 相同，
+// 迴圈主體在鎖外執行，可以修改管道
+func (cl *ChunkPipe[T]) Values() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		cl.RangeValues(yield)
+	}
+}
+
+// All 返回依序走訪元素的迭代器，索引為走訪開始後的第幾個元素
+func (cl *ChunkPipe[T]) All() iter.Seq2[int, T] {
+	return func(yield func(int, T) bool) {
+		i := 0
+		cl.RangeValues(func(v T) bool {
+			if !yield(i, v) {
+				return false
+			}
+			i++
+			return true
+		})
+	}
+}
+
+// Backward 返回從尾端往頭部走訪的迭代器。
+// 與 RangeValues 相同每次在讀鎖內複製一批元素，走訪期間新推入的元素不會被走訪
+func (cl *ChunkPipe[T]) Backward() iter.Seq[T] {
+	return func(yield func(T) bool) {
+		buf := make([]T, defaultRangeBatch)
+		cl.mu.RLock()
+		end := cl.offset + cl.len()
+		cl.mu.RUnlock()
+
+		for {
+			cl.mu.RLock()
+			end = min(end, cl.offset+cl.len())
+			start := max(end-len(buf), cl.offset)
+			n := 0
+			if start < end {
+				n = cl.copyAt(buf[:end-start], start)
+			}
+			cl.mu.RUnlock()
+
+			for i := n - 1; i >= 0; i-- {
+				if !yield(buf[i]) {
+					return
+				}
+			}
+			if n == 0 {
+				return
+			}
+			end = start
+		}
+	}
+}
+
+// Chunks 返回依序走訪數據塊的迭代器，每次只在取得下一個區塊時持有鎖。
+// 區塊視圖直接引用管道內部記憶體，在迴圈本體執行期間不會被重複使用，
+// 保留到迴圈本體之外則與 ChunkSlice 相同，只在下一次彈出或推入之前有效；
+// 走訪期間被彈出的部分會被略過，區塊被切分時會從上次走訪到的位置繼續
+func (cl *ChunkPipe[T]) Chunks() iter.Seq[[]T] {
+	return func(yield func([]T) bool) {
+		pos := 0
+		for {
+			cl.mu.Lock()
+			pos = max(pos, cl.offset)
+			i, p, ok := cl.find(pos)
+			if !ok {
+				cl.mu.Unlock()
+				return
+			}
+			val := cl.list[i].val
+			view := val[p:len(val):len(val)]
+			cl.pin()
+			cl.mu.Unlock()
+
+			if !cl.yieldPinned(yield, view) {
+				return
+			}
+			pos += len(view)
+		}
+	}
+}
+
+// yieldPinned 以 pin 住的 view 呼叫 yield，返回（包含 yield panic）時結束 pin
+func (cl *ChunkPipe[T]) yieldPinned(yield func([]T) bool, view []T) bool {
+	defer func() {
+		cl.mu.Lock()
+		cl.unpin()
+		cl.mu.Unlock()
+	}()
+	return yield(view)
+}
+
+// Cursor 返回一個從目前頭部開始的游標
+func (cl *ChunkPipe[T]) Cursor() *Cursor[T] {
+	c := &Cursor[T]{pipe: cl}
+	c.Reset()
+	return c
+}
+
+// CheckInvariants 驗證內部結構的一致性（見 ChunkPipe 的不變量說明），
+// 發現不一致時返回描述第一個問題的錯誤。適合在自己的模糊測試中於每步操作後呼叫
+func (cl *ChunkPipe[T]) CheckInvariants() error {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	return cl.checkInvariants()
+}
+
+// checkInvariants 驗證內部結構的一致性，呼叫者需持有鎖
+func (cl *ChunkPipe[T]) checkInvariants() error {
+	start := cl.offset
+	total := 0
+	for i, off := range cl.list {
+		if len(off.val) == 0 {
+			return fmt.Errorf("chunk %d is empty", i)
+		}
+		if off.off-len(off.val) != start {
+			return fmt.Errorf("chunk %d starts at %d, want %d", i, off.off-len(off.val), start)
+		}
+		if off.cap != 0 && off.cap != cap(off.val) {
+			return fmt.Errorf("chunk %d cap = %d, backing cap = %d", i, off.cap, cap(off.val))
+		}
+		if off.shared && off.cap != 0 {
+			return fmt.Errorf("chunk %d is shared but owns cap %d", i, off.cap)
+		}
+		if off.dead < 0 {
+			return fmt.Errorf("chunk %d dead = %d", i, off.dead)
+		}
+		start = off.off
+		total += len(off.val)
+	}
+	if n := cl.len(); n != total {
+		return fmt.Errorf("len = %d, sum of chunk sizes = %d", n, total)
+	}
+	return nil
+}
+
 // ValueIter 返回值迭代器
 func (cl *ChunkPipe[T]) ValueIter() *ValueIterator[T] {
 	return &ValueIterator[T]{
@@ -196,7 +2325,7 @@ func (cl *ChunkPipe[T]) ChunkIter() *ChunkIterator[T] {
 func (it *ValueIterator[T]) Next() bool {
 	// 先增加位置
 	it.pos++
-	return it.pos < it.pipe.size()
+	return it.pos < it.pipe.Len()
 }
 
 func (it *ValueIterator[T]) V() T {
@@ -210,9 +2339,11 @@ func (it *ChunkIterator[T]) Next() bool {
 	return it.pos < len(it.pipe.list)
 }
 
+// V 返回目前的數據塊，與 ChunkSlice 相同直接引用管道內部記憶體，只在下一次彈出或推入之前有效
 func (it *ChunkIterator[T]) V() []T {
 	if it.pos < len(it.pipe.list) && it.pos >= 0 {
-		return it.pipe.list[it.pos].val
+		val := it.pipe.list[it.pos].val
+		return val[:len(val):len(val)]
 	}
 	var zero []T
 	return zero
@@ -0,0 +1,73 @@
+package chunkpipe
+
+import (
+	"strconv"
+	"time"
+)
+
+// Collector 接收管道的操作指標，可轉接到 expvar、Prometheus 等監控系統。
+// 所有方法都在釋放鎖之後呼叫，可能由多個 goroutine 同時呼叫，實作需自行保證並發安全
+type Collector interface {
+	// Pushed 在 n 個元素加入後呼叫
+	Pushed(n int)
+	// Popped 在 n 個元素被移除後呼叫，包含 Discard、Clear 與移動到其他管道
+	Popped(n int)
+	// ChunksAllocated 在推入時新配置了 n 個區塊後呼叫，重複使用的區塊不計入
+	ChunksAllocated(n int)
+	// Blocked 在阻塞式的彈出或推入每次等待結束後呼叫，d 為這次等待的時間
+	Blocked(d time.Duration)
+}
+
+// WithMetrics 設定接收操作指標的 Collector；由此管道衍生的管道（Clone、Split 等）沿用同一個 Collector
+func WithMetrics(c Collector) Option {
+	return func(o *options) {
+		o.metrics = c
+	}
+}
+
+// blocked 回報從 start 起的等待時間
+func (cl *ChunkPipe[T]) blocked(start time.Time) {
+	if cl.metrics != nil {
+		cl.metrics.Blocked(time.Since(start))
+	}
+}
+
+// Op 為 WithTraceHook 回報的操作種類
+type Op uint8
+
+const (
+	// OpPush 表示元素被加入，包含推入頭部、插入與從其他管道移入
+	OpPush Op = iota + 1
+	// OpPop 表示元素被移除，包含彈出、Discard 與移動到其他管道
+	OpPop
+	// OpClear 表示 Clear 移除了所有元素
+	OpClear
+)
+
+func (op Op) String() string {
+	switch op {
+	case OpPush:
+		return "push"
+	case OpPop:
+		return "pop"
+	case OpClear:
+		return "clear"
+	}
+	return "op(" + strconv.Itoa(int(op)) + ")"
+}
+
+// WithTraceHook 設定每次加入或移除元素時呼叫的 fn，n 為元素數量，用於記錄事件以重現並發時的交錯順序。
+// fn 在持有寫鎖時呼叫，因此事件順序與實際修改順序一致；fn 必須快速返回且不可操作管道。
+// 由此管道衍生的管道沿用同一個 fn
+func WithTraceHook(fn func(op Op, n int)) Option {
+	return func(o *options) {
+		o.trace = fn
+	}
+}
+
+// emit 在 n 大於 0 時回報追蹤事件，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) emit(op Op, n int) {
+	if n > 0 && cl.trace != nil {
+		cl.trace(op, n)
+	}
+}
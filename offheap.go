@@ -0,0 +1,26 @@
+package chunkpipe
+
+import "unsafe"
+
+// WithOffHeap 讓不含指標的元素型別從匿名映射（mmap）配置自有區塊，
+// 大量排隊中的元素不在 Go 的堆積上，不增加垃圾回收的負擔。
+// 等同以映射配置器呼叫 WithAllocator，限制與區塊的生命週期見 WithAllocator；
+// 平台不支援 mmap 時改用一般的堆積配置
+func WithOffHeap() Option {
+	return WithAllocator(mmapAllocator{})
+}
+
+// mmapAllocator 以匿名映射配置記憶體，映射以頁為單位，總是滿足元素的對齊
+type mmapAllocator struct{}
+
+func (mmapAllocator) Alloc(nBytes int) unsafe.Pointer {
+	b, err := mmapBytes(nBytes)
+	if err != nil {
+		return nil
+	}
+	return unsafe.Pointer(unsafe.SliceData(b))
+}
+
+func (mmapAllocator) Free(ptr unsafe.Pointer, nBytes int) {
+	munmapBytes(unsafe.Slice((*byte)(ptr), nBytes))
+}
@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+// +build linux darwin freebsd netbsd openbsd dragonfly
+
+package chunkpipe
+
+import "syscall"
+
+// mmapBytes 從匿名映射取得 n 位元組的記憶體，這些記憶體不在 Go 的堆積上
+func mmapBytes(n int) ([]byte, error) {
+	return syscall.Mmap(-1, 0, n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+}
+
+// munmapBytes 釋放由 mmapBytes 取得的記憶體
+func munmapBytes(b []byte) error {
+	return syscall.Munmap(b)
+}
@@ -0,0 +1,17 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package chunkpipe
+
+import "errors"
+
+var errNoMmap = errors.New("chunkpipe: mmap is not supported on this platform")
+
+// mmapBytes 在不支援匿名映射的平台上一律失敗，WithOffHeap 因此改用一般的堆積配置
+func mmapBytes(n int) ([]byte, error) {
+	return nil, errNoMmap
+}
+
+func munmapBytes(b []byte) error {
+	return errNoMmap
+}
@@ -0,0 +1,149 @@
+package chunkpipe
+
+import (
+	"reflect"
+	"time"
+	"unsafe"
+)
+
+// Option 設定 New 建立的 ChunkPipe
+type Option func(*options)
+
+type options struct {
+	initialCapacity int
+	chunkSize       int
+	tree            bool
+	copyOnPush      bool
+	maxLen          int
+	overflow        OverflowPolicy
+	timestamps      bool
+	ttl             time.Duration
+	noLock          bool
+	metrics         Collector
+	trace           func(op Op, n int)
+	allocator       Allocator
+	compactFill     float64
+	trimRatio       float64
+	normalize       bool
+}
+
+func defaultOptions() options {
+	return options{
+		chunkSize:  defaultChunkSize,
+		tree:       true,
+		copyOnPush: true,
+		trimRatio:  trimDeadRatio,
+	}
+}
+
+// WithInitialCapacity 預先分配容量為 n 的首個區塊，之後的小資料 Push 會先填入此區塊
+func WithInitialCapacity(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.initialCapacity = n
+		}
+	}
+}
+
+// WithChunkSize 設定管道自有區塊的目標容量
+func WithChunkSize(n int) Option {
+	return func(o *options) {
+		if n > 0 {
+			o.chunkSize = n
+		}
+	}
+}
+
+// WithChunkNormalization 設定推入時是否讓區塊維持在 WithChunkSize 的大小附近（預設為 false）。
+// 開啟時超過區塊大小的推入會切成多個區塊，複製時分段寫入，直接引用或 PushOwned 時切成多個視圖；
+// 小於區塊大小的推入即使關閉了 WithCopyOnPush 也會複製進尾端的區塊合併。
+// 區塊大小一致讓 Get 的定位與 PopChunkFront 的粒度更穩定，代價是較大的推入不再是單一區塊
+func WithChunkNormalization(enabled bool) Option {
+	return func(o *options) {
+		o.normalize = enabled
+	}
+}
+
+// WithTree 設定是否維護區塊偏移索引；關閉時 Get 會改為線性掃描區塊
+func WithTree(enabled bool) Option {
+	return func(o *options) {
+		o.tree = enabled
+	}
+}
+
+// WithCopyOnPush 設定 Push 是否複製傳入的切片（預設為 true）。
+// 關閉時超過 smallPushSize 的切片會被直接引用，速度較快，
+// 但呼叫者之後修改該切片會反映在管道內容上
+func WithCopyOnPush(enabled bool) Option {
+	return func(o *options) {
+		o.copyOnPush = enabled
+	}
+}
+
+// WithMaxLen 設定元素數量上限，效果與 NewBounded 相同；n 小於等於 0 時不設上限
+func WithMaxLen(n int) Option {
+	return func(o *options) {
+		o.maxLen = max(n, 0)
+	}
+}
+
+// WithAutoCompact 在修改後區塊的平均填充率（元素數量除以區塊數量乘區塊大小）低於 fill 時自動執行 Compact。
+// fill 介於 0 與 0.5 之間較為合適，過高時合併後仍可能低於門檻而反覆整理；fill 小於等於 0 時停用
+func WithAutoCompact(fill float64) Option {
+	return func(o *options) {
+		o.compactFill = max(fill, 0)
+	}
+}
+
+// WithTrimRatio 設定頭部區塊已彈出的前綴達到剩餘元素的幾倍時重新配置頭部區塊，
+// 讓已消費的記憶體提早被回收，預設為 3。比例越小越早釋放，但複製越頻繁；
+// ratio 小於等於 0 時 PopFront 不再自動重新配置，只在呼叫 TrimFront 或 Shrink 時進行
+func WithTrimRatio(ratio float64) Option {
+	return func(o *options) {
+		o.trimRatio = max(ratio, 0)
+	}
+}
+
+// WithNoLock 停用管道內部的讀寫鎖，省去單一 goroutine 使用時的加鎖開銷。
+// 停用後管道不再是並發安全的，阻塞式的彈出與推入也只能由其他 goroutine 喚醒
+func WithNoLock() Option {
+	return func(o *options) {
+		o.noLock = true
+	}
+}
+
+// New 建立一個套用指定選項的 ChunkPipe
+func New[T any](opts ...Option) *ChunkPipe[T] {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	cl := &ChunkPipe[T]{
+		chunkSize:   o.chunkSize,
+		noTree:      !o.tree,
+		alias:       !o.copyOnPush,
+		pointers:    hasPointers(reflect.TypeFor[T]()),
+		maxLen:      o.maxLen,
+		overflow:    o.overflow,
+		stamped:     o.timestamps,
+		ttl:         o.ttl,
+		metrics:     o.metrics,
+		trace:       o.trace,
+		compactFill: o.compactFill,
+		trimRatio:   o.trimRatio,
+		normalize:   o.normalize,
+	}
+	cl.mu.disabled = o.noLock
+	// 外部記憶體不受垃圾回收掃描，只能存放不含指標的元素
+	if !cl.pointers && unsafe.Sizeof(*new(T)) > 0 {
+		cl.allocator = o.allocator
+	}
+	if o.initialCapacity > 0 {
+		cl.spare = make([]T, 0, o.initialCapacity)
+	}
+	if o.ttl > 0 && !o.noLock {
+		go cl.janitor(o.ttl)
+	}
+	return cl
+}
@@ -0,0 +1,64 @@
+package chunkpipe
+
+import "sync/atomic"
+
+// ShardedChunkPipe 將 Push 輪流分散到多個各自加鎖的 ChunkPipe，
+// 以降低高併發寫入時的鎖競爭。
+//
+// 元素順序只在單一分片內保證；Range 與 Drain 依分片編號依序合併，
+// 因此不同分片之間的元素不保留推入的先後順序。
+type ShardedChunkPipe[T any] struct {
+	shards []*ChunkPipe[T]
+	next   atomic.Uint64
+}
+
+// NewSharded 建立一個擁有 n 個分片的 ShardedChunkPipe，每個分片皆套用 opts
+func NewSharded[T any](n int, opts ...Option) *ShardedChunkPipe[T] {
+	if n <= 0 {
+		n = 1
+	}
+	sp := &ShardedChunkPipe[T]{
+		shards: make([]*ChunkPipe[T], n),
+	}
+	for i := range sp.shards {
+		sp.shards[i] = New[T](opts...)
+	}
+	return sp
+}
+
+// Push 將數據整塊推入下一個分片，支援鏈式呼叫
+func (sp *ShardedChunkPipe[T]) Push(data []T) *ShardedChunkPipe[T] {
+	i := (sp.next.Add(1) - 1) % uint64(len(sp.shards))
+	sp.shards[i].Push(data)
+	return sp
+}
+
+// Len 返回所有分片的元素總數
+func (sp *ShardedChunkPipe[T]) Len() int {
+	n := 0
+	for _, shard := range sp.shards {
+		n += shard.Len()
+	}
+	return n
+}
+
+// Range 依分片編號依序對每個元素呼叫 fn，fn 返回 false 時停止
+func (sp *ShardedChunkPipe[T]) Range(fn func(value T) bool) {
+	stopped := false
+	for _, shard := range sp.shards {
+		shard.RangeValues(func(v T) bool {
+			stopped = !fn(v)
+			return !stopped
+		})
+		if stopped {
+			return
+		}
+	}
+}
+
+// Drain 依分片編號依序取出所有元素並對每個元素呼叫 fn
+func (sp *ShardedChunkPipe[T]) Drain(fn func(value T)) {
+	for _, shard := range sp.shards {
+		shard.Drain(fn)
+	}
+}
@@ -0,0 +1,42 @@
+package chunkpipe
+
+// Snapshot 是 ChunkPipe 在某一時刻的唯讀副本，讀取時不需要加鎖，
+// 也不受來源管道後續修改的影響
+type Snapshot[T any] struct {
+	val []T
+}
+
+// Snapshot 複製目前所有元素並返回唯讀快照
+func (cl *ChunkPipe[T]) Snapshot() *Snapshot[T] {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	val := make([]T, 0, cl.len())
+	for i := range cl.list {
+		val = append(val, cl.list[i].val...)
+	}
+	return &Snapshot[T]{val: val}
+}
+
+// Len 返回快照中的元素數量
+func (s *Snapshot[T]) Len() int {
+	return len(s.val)
+}
+
+// Get 返回快照中指定索引的元素
+func (s *Snapshot[T]) Get(index int) (T, bool) {
+	if index < 0 || index >= len(s.val) {
+		var zero T
+		return zero, false
+	}
+	return s.val[index], true
+}
+
+// Range 依序對每個元素呼叫 fn，fn 返回 false 時停止
+func (s *Snapshot[T]) Range(fn func(index int, value T) bool) {
+	for i, v := range s.val {
+		if !fn(i, v) {
+			return
+		}
+	}
+}
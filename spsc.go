@@ -0,0 +1,90 @@
+package chunkpipe
+
+import "sync/atomic"
+
+// SPSCChunkPipe 是單一生產者、單一消費者專用的無鎖管道。
+//
+// 併發約定：同一時間最多只能有一個 goroutine 呼叫 Push，
+// 且最多只能有一個 goroutine 呼叫 PopFront；Len 可由任意 goroutine 呼叫。
+// 生產者與消費者可以是不同的 goroutine。若有多個生產者或多個消費者，
+// 請改用以互斥鎖保護的 ChunkPipe。
+//
+// Push 會將資料複製到管道自有的固定大小區段，生產者透過原子寫入已發布的
+// 元素數量來交付資料，消費者讀到該數量後才讀取對應的元素。
+type SPSCChunkPipe[T any] struct {
+	// head 與 pos 只由消費者存取
+	head *spscSegment[T]
+	pos  int
+	// tail 只由生產者存取
+	tail *spscSegment[T]
+
+	pushed atomic.Int64
+	popped atomic.Int64
+}
+
+type spscSegment[T any] struct {
+	val  []T
+	n    atomic.Int64 // 已發布的元素數量，只由生產者寫入
+	next atomic.Pointer[spscSegment[T]]
+}
+
+// NewSPSC 建立一個區段容量為 segmentSize 的 SPSCChunkPipe
+func NewSPSC[T any](segmentSize int) *SPSCChunkPipe[T] {
+	if segmentSize <= 0 {
+		segmentSize = defaultChunkSize
+	}
+	seg := &spscSegment[T]{val: make([]T, segmentSize)}
+	return &SPSCChunkPipe[T]{
+		head: seg,
+		tail: seg,
+	}
+}
+
+// Push 將數據複製到管道尾端，只能由生產者呼叫
+func (p *SPSCChunkPipe[T]) Push(data []T) *SPSCChunkPipe[T] {
+	for len(data) > 0 {
+		tail := p.tail
+		n := int(tail.n.Load())
+		if n == len(tail.val) {
+			seg := &spscSegment[T]{val: make([]T, len(tail.val))}
+			tail.next.Store(seg)
+			p.tail = seg
+			continue
+		}
+		k := copy(tail.val[n:], data)
+		data = data[k:]
+		tail.n.Store(int64(n + k))
+		p.pushed.Add(int64(k))
+	}
+	return p
+}
+
+// PopFront 從頭部取出一個元素，只能由消費者呼叫
+func (p *SPSCChunkPipe[T]) PopFront() (T, bool) {
+	var zero T
+	for {
+		head := p.head
+		if p.pos < int(head.n.Load()) {
+			ret := head.val[p.pos]
+			head.val[p.pos] = zero
+			p.pos++
+			p.popped.Add(1)
+			return ret, true
+		}
+		if p.pos < len(head.val) {
+			return zero, false
+		}
+		next := head.next.Load()
+		if next == nil {
+			return zero, false
+		}
+		p.head = next
+		p.pos = 0
+	}
+}
+
+// Len 返回目前的元素數量；與 Push/PopFront 併發時為近似值
+func (p *SPSCChunkPipe[T]) Len() int {
+	popped := p.popped.Load()
+	return int(p.pushed.Load() - popped)
+}
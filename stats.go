@@ -0,0 +1,139 @@
+package chunkpipe
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unsafe"
+)
+
+// Stats 描述管道目前的記憶體使用情況與累計的操作次數
+type Stats struct {
+	// Chunks 為數據塊數量
+	Chunks int
+	// Elements 為有效元素數量
+	Elements int
+	// AllocatedElements 為各數據塊底層陣列實際佔用的容量總和，
+	// 包含頭部已彈出的前綴與尾端尚未使用的空間
+	AllocatedElements int
+	// DeadElements 為頭部已彈出、但仍佔用底層陣列的元素數量
+	DeadElements int
+
+	// LiveBytes、AllocatedBytes 與 DeadBytes 為對應元素數量乘上元素大小，
+	// 不包含元素內部指標所引用的記憶體
+	LiveBytes      int
+	AllocatedBytes int
+	DeadBytes      int
+
+	// AvgChunkFill 為各數據塊有效元素佔其底層容量比例的平均值，沒有數據塊時為 0
+	AvgChunkFill float64
+
+	// Pushed 與 Popped 為建立以來累計推入與移除的元素數量
+	Pushed uint64
+	Popped uint64
+	// ChunksAllocated 為推入時新配置區塊的次數，
+	// ChunksReused 為重複使用預先分配或池中區塊的次數
+	ChunksAllocated uint64
+	ChunksReused    uint64
+}
+
+// Stats 返回目前的記憶體使用統計
+func (cl *ChunkPipe[T]) Stats() Stats {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	s := Stats{
+		Chunks:            len(cl.list),
+		Elements:          cl.len(),
+		AllocatedElements: cl.allocated(),
+		Pushed:            cl.totalPushed,
+		Popped:            cl.totalPopped,
+		ChunksAllocated:   cl.chunksAllocated,
+		ChunksReused:      cl.chunksReused,
+	}
+	for i := range cl.list {
+		c := cl.list[i]
+		s.DeadElements += c.dead
+		s.AvgChunkFill += float64(len(c.val)) / float64(c.dead+cap(c.val))
+	}
+	if s.Chunks > 0 {
+		s.AvgChunkFill /= float64(s.Chunks)
+	}
+	size := int(unsafe.Sizeof(*new(T)))
+	s.LiveBytes = s.Elements * size
+	s.AllocatedBytes = s.AllocatedElements * size
+	s.DeadBytes = s.DeadElements * size
+	return s
+}
+
+// allocated 返回各數據塊底層陣列佔用的容量總和，呼叫者需持有鎖
+func (cl *ChunkPipe[T]) allocated() int {
+	n := 0
+	for i := range cl.list {
+		n += cl.list[i].dead + cap(cl.list[i].val)
+	}
+	return n
+}
+
+// LoadFactor 返回有效元素佔已配置容量的比例，沒有配置任何容量時返回 1
+func (cl *ChunkPipe[T]) LoadFactor() float64 {
+	s := cl.Stats()
+	if s.AllocatedElements == 0 {
+		return 1
+	}
+	return float64(s.Elements) / float64(s.AllocatedElements)
+}
+
+// stringPreview 為 String 最多顯示的元素數量
+const stringPreview = 8
+
+// String 返回長度、數據塊數量與前幾個元素的預覽，實作 fmt.Stringer
+func (cl *ChunkPipe[T]) String() string {
+	cl.mu.RLock()
+	n, chunks := cl.len(), len(cl.list)
+	preview := make([]T, min(n, stringPreview))
+	cl.copyAt(preview, cl.offset)
+	cl.mu.RUnlock()
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "ChunkPipe(len=%d, chunks=%d) [", n, chunks)
+	for i, v := range preview {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprint(&sb, v)
+	}
+	if n > len(preview) {
+		sb.WriteString(" ...")
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// DebugDump 將內部佈局寫入 w：整體設定，以及每個數據塊的絕對位置、長度、容量與所有權，
+// 用於診斷碎片化。內容在讀鎖內產生，寫入 w 時不持有鎖
+func (cl *ChunkPipe[T]) DebugDump(w io.Writer) error {
+	var sb strings.Builder
+	cl.mu.RLock()
+	index := "binary search"
+	if cl.noTree {
+		index = "linear scan"
+	}
+	fmt.Fprintf(&sb, "ChunkPipe len=%d offset=%d chunks=%d allocated=%d chunkSize=%d index=%s\n",
+		cl.len(), cl.offset, len(cl.list), cl.allocated(), cl.chunkSize, index)
+	for i, c := range cl.list {
+		kind := "owned"
+		switch {
+		case c.shared:
+			kind = "shared"
+		case c.cap == 0:
+			kind = "borrowed"
+		}
+		fmt.Fprintf(&sb, "  #%d [%d, %d) len=%d cap=%d dead=%d %s\n",
+			i, c.off-len(c.val), c.off, len(c.val), cap(c.val), c.dead, kind)
+	}
+	cl.mu.RUnlock()
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
@@ -1,21 +1,176 @@
 package chunkpipe
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// smallPushSize 以下的資料在 Push 時複製進管道自有的區塊，避免產生大量細碎區塊
+	smallPushSize = 8
+	// defaultChunkSize 管道自有區塊的預設容量
+	defaultChunkSize = 256
+	// defaultRangeBatch 為 RangeValues 每次加鎖複製的元素數量
+	defaultRangeBatch = 16
+	// trimMinDead 與 trimDeadRatio 控制 PopFront 何時重新配置頭部區塊：
+	// 已彈出的前綴至少 trimMinDead 個元素，且為剩餘元素的 trimDeadRatio 倍以上；
+	// trimDeadRatio 為預設值，可以 WithTrimRatio 調整
+	trimMinDead   = 64
+	trimDeadRatio = 3
+	// freeListSize 為每個管道保留的已取空區塊數量上限
+	freeListSize = 4
+	// minCompactChunks 為 WithAutoCompact 開始檢查填充率的最少區塊數量
+	minCompactChunks = 8
+)
 
 // 定義 Chunk 結構，用於存儲任意型別數據塊
+//
+// 不變量（由 CheckInvariants 驗證）：
+//   - list 中不保留空區塊，任何操作清空區塊時都必須同時將其移除
+//   - 每個區塊的起點 off-len(val) 等於前一個區塊的 off，首個區塊的起點等於 offset
+//   - 元素總數等於 list[len(list)-1].off - offset，也就是所有 len(val) 的總和
+//   - cap 為 0（借用區塊）或等於 cap(val)（自有區塊），共用區塊的 cap 一律為 0
+//   - dead 不為負數
+//
+// 同時鎖定兩個管道的操作一律依記憶體位址由低到高取得鎖（見 rlockPair 與 lockPair），
+// 避免 a 對 b 與 b 對 a 的操作交錯時互相等待
 type ChunkPipe[T any] struct {
 	offset int
 	list   []offset[T]
-	mu     sync.RWMutex
+	mu     rwMutex
+
+	// spare 為預先分配、尚未放入 list 的自有區塊
+	spare []T
+	// pool 收集經 PopChunkFrontPooled 歸還的自有區塊
+	pool sync.Pool
+	// free 保留最近取空的自有區塊，之後的推入優先重複使用
+	free [][]T
+
+	chunkSize int
+	noTree    bool
+	// alias 為 true 時 Push 直接引用較大的切片而不複製
+	alias bool
+	// pointers 表示元素型別包含指標，移出的 slots 需要清為零值
+	pointers bool
+
+	onPush  func(n int)
+	onPop   func(n int)
+	onEvict func(batch []T)
+	// evicted 為持有寫鎖期間被丟棄、等待釋放鎖後交給 onEvict 的元素
+	evicted []T
+	notify  chan struct{}
+	// ready 由等待資料的阻塞彈出建立，管道由空轉為非空時關閉以喚醒所有等待者
+	ready chan struct{}
+
+	// maxLen 為元素數量上限，0 表示不限制；建立後不再改變
+	maxLen int
+	// stamped 為 true 時記錄推入時間，見 WithTimestamps；ttl 為 WithTTL 設定的存活時間
+	stamped bool
+	ttl     time.Duration
+	// stamps 依位置遞增記錄尚未完全彈出的元素的推入時間
+	stamps []stamp
+	// pinned 為釋放鎖後仍在使用的區塊視圖數量（見 pin），parked 為期間暫存、尚未重複使用的陣列
+	pinned int
+	parked [][]T
+	// untimed 為這次持有寫鎖期間推入到尾端以外位置的元素數量，release 時不為它們記錄時間
+	untimed int
+	// overflow 為 WithOverflow 設定的策略，只在 maxLen 大於 0 時有作用
+	overflow OverflowPolicy
+	// space 由等待空間的推入建立，有元素被移除時關閉以喚醒所有等待者
+	space chan struct{}
+	// closed 在 Close 之後為 true，之後不再接受推入
+	closed bool
+
+	// 以下為 Stats 回報的累計計數，只在持有寫鎖時更新
+	totalPushed     uint64
+	totalPopped     uint64
+	chunksAllocated uint64
+	chunksReused    uint64
+
+	// metrics 為 WithMetrics 設定的收集器，建立後不再改變
+	metrics Collector
+	// reportedAllocs 為已回報給 metrics 的 chunksAllocated
+	reportedAllocs uint64
+	// trace 為 WithTraceHook 設定的回呼，建立後不再改變
+	trace func(op Op, n int)
+
+	// normalize 為 true 時推入的資料依 chunkSize 切分或合併，見 WithChunkNormalization
+	normalize bool
+	// trimRatio 為 WithTrimRatio 設定的已彈出前綴比例，見 trimHead
+	trimRatio float64
+	// compactFill 為 WithAutoCompact 設定的平均填充率門檻，0 表示不自動合併
+	compactFill float64
+
+	// allocator 不為 nil 時新的自有區塊由它配置，見 WithAllocator
+	allocator Allocator
+	// external 以底層陣列起點記錄目前由此管道向 allocator 取得、尚未歸還的區塊及其位元組數
+	external map[*T]int
+}
+
+// rwMutex 是可以停用的讀寫鎖，以 WithNoLock 建立的管道所有加鎖操作都不做任何事
+type rwMutex struct {
+	sync.RWMutex
+	disabled bool
+}
+
+func (m *rwMutex) Lock() {
+	if !m.disabled {
+		m.RWMutex.Lock()
+	}
+}
+
+func (m *rwMutex) Unlock() {
+	if !m.disabled {
+		m.RWMutex.Unlock()
+	}
+}
+
+func (m *rwMutex) RLock() {
+	if !m.disabled {
+		m.RWMutex.RLock()
+	}
+}
+
+func (m *rwMutex) RUnlock() {
+	if !m.disabled {
+		m.RWMutex.RUnlock()
+	}
 }
 
 type offset[T any] struct {
 	off int
 	val []T
+	// cap 為管道自有且可原地追加的容量，恆等於 cap(val)；
+	// 借用呼叫者記憶體的區塊為 0，永遠不會被原地寫入
+	cap int
+	// dead 為已從頭部彈出、但仍佔用底層陣列的元素數量
+	dead int
+	// shared 表示底層陣列與其他管道共用（見 CloneShared），cap 必定為 0，
+	// 原地修改前必須先以 unshare 複製
+	shared bool
 }
 
 func NewChunkPipe[T any]() *ChunkPipe[T] {
-	return &ChunkPipe[T]{}
+	return New[T]()
+}
+
+// Repeat 建立一個包含 count 個 v 的 ChunkPipe
+func Repeat[T any](v T, count int) *ChunkPipe[T] {
+	cl := New[T]()
+	if count <= 0 {
+		return cl
+	}
+
+	val := make([]T, count)
+	for i := range val {
+		val[i] = v
+	}
+	cl.list = append(cl.list, offset[T]{
+		val: val,
+		off: count,
+		cap: count,
+	})
+	return cl
 }
 
 // ValueIterator 提供值迭代器
@@ -0,0 +1,164 @@
+package chunkpipe
+
+import "time"
+
+// stampResolution 內的連續推入共用同一筆時間戳記，避免大量小量推入各自佔用一筆紀錄
+const stampResolution = time.Millisecond
+
+// stamp 記錄絕對位置 off 之前、上一筆紀錄之後推入的元素的推入時間
+type stamp struct {
+	off int
+	at  time.Time
+}
+
+// WithTimestamps 記錄每次推入到尾端的時間，之後可用 PopOlderThan 取出放置過久的元素，
+// 適合當作延遲緩衝區。時間以 1 毫秒為解析度，同一毫秒內的推入視為同時。
+// 推入頭部或插入中間的元素沿用所在位置原本的時間，Filter、RemoveAt 等從中間移除元素後，
+// 其餘元素保留各自的推入時間；以 Append 移入的元素以移入的時間計算。
+// Sort、Reverse 等重排元素的操作不移動時間，之後的時間只是近似值。
+// Split、Partition、SubPipe、Clone 等建立的新管道不記錄時間，也不繼承 WithTTL 的背景清理
+func WithTimestamps() Option {
+	return func(o *options) {
+		o.timestamps = true
+	}
+}
+
+// WithTTL 記錄推入時間，並啟動背景清理定期移除放置超過 ttl 的元素，移除的元素會交給 OnEvict。
+// 清理大約每 ttl/4 執行一次，在 Close 之後停止，因此不再使用的管道需要呼叫 Close；
+// 以 WithNoLock 建立的管道不啟動背景清理，需自行呼叫 PopExpired；
+// 與 WithTimestamps 相同，Split 等建立的新管道不會過期
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		if ttl > 0 {
+			o.timestamps = true
+			o.ttl = ttl
+		}
+	}
+}
+
+// PopOlderThan 從頭部彈出所有推入超過 d 的元素，沒有時返回空切片。
+// 管道未以 WithTimestamps 或 WithTTL 建立時不記錄時間，一律返回空切片
+func (cl *ChunkPipe[T]) PopOlderThan(d time.Duration) []T {
+	cl.mu.Lock()
+	n := cl.expired(time.Now().Add(-d))
+	ret := make([]T, n)
+	cl.copyAt(ret, cl.offset)
+	cl.discardFront(n)
+	cl.unlock(0, n)
+	return ret
+}
+
+// PopExpired 從頭部彈出所有超過 WithTTL 設定時間的元素，等同 PopOlderThan(ttl)；
+// 未設定 ttl 時返回空切片
+func (cl *ChunkPipe[T]) PopExpired() []T {
+	if cl.ttl <= 0 {
+		return []T{}
+	}
+	return cl.PopOlderThan(cl.ttl)
+}
+
+// OldestAge 返回頭部元素推入至今經過的時間，管道為空或沒有記錄時間時返回 false
+func (cl *ChunkPipe[T]) OldestAge() (time.Duration, bool) {
+	cl.mu.RLock()
+	defer cl.mu.RUnlock()
+
+	for _, s := range cl.stamps {
+		if s.off > cl.offset {
+			return time.Since(s.at), true
+		}
+	}
+	return 0, false
+}
+
+// stamp 為尾端新推入的 n 個元素記錄推入時間，並丟棄已完全彈出的紀錄，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) stamp(n int) {
+	i := 0
+	for i < len(cl.stamps) && cl.stamps[i].off <= cl.offset {
+		i++
+	}
+	cl.stamps = cl.stamps[i:]
+
+	end := cl.offset + cl.len()
+	start := end - n
+	// 尾端的元素曾被移除時，之前的紀錄可能涵蓋到新元素的位置
+	for k := len(cl.stamps) - 1; k >= 0 && cl.stamps[k].off > start; k-- {
+		if k > 0 && cl.stamps[k-1].off >= start {
+			cl.stamps = cl.stamps[:k]
+		} else {
+			cl.stamps[k].off = start
+		}
+	}
+
+	now := time.Now()
+	if k := len(cl.stamps) - 1; k >= 0 && now.Sub(cl.stamps[k].at) < stampResolution {
+		cl.stamps[k].off = end
+		return
+	}
+	cl.stamps = append(cl.stamps, stamp{off: end, at: now})
+}
+
+// shiftStamps 在絕對位置 pos 移除 removed 個元素並插入 inserted 個元素時調整時間戳記，
+// 讓後面的元素保留原本的推入時間，插入的元素沿用後一個元素的時間，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) shiftStamps(pos, removed, inserted int) {
+	for i := range cl.stamps {
+		s := &cl.stamps[i]
+		switch {
+		case s.off > pos+removed:
+			s.off += inserted - removed
+		case s.off > pos:
+			s.off = pos
+		}
+	}
+}
+
+// stampShift 在依位置由小到大逐一移除元素時調整時間戳記，用於 filter 等一次移除多個分散元素的操作
+type stampShift struct {
+	stamps  []stamp
+	k       int
+	removed int
+}
+
+// remove 記錄移除原本位於絕對位置 pos 的元素
+func (s *stampShift) remove(pos int) {
+	for ; s.k < len(s.stamps) && s.stamps[s.k].off <= pos; s.k++ {
+		s.stamps[s.k].off -= s.removed
+	}
+	s.removed++
+}
+
+// done 調整剩下的時間戳記，所有移除都記錄完之後呼叫
+func (s *stampShift) done() {
+	for ; s.k < len(s.stamps); s.k++ {
+		s.stamps[s.k].off -= s.removed
+	}
+}
+
+// expired 返回頭部推入時間早於 cutoff 的元素數量，呼叫者需持有寫鎖
+func (cl *ChunkPipe[T]) expired(cutoff time.Time) int {
+	end := cl.offset
+	for _, s := range cl.stamps {
+		if !s.at.Before(cutoff) {
+			break
+		}
+		end = s.off
+	}
+	return min(max(end-cl.offset, 0), cl.len())
+}
+
+// janitor 每隔 ttl/4 移除放置超過 ttl 的元素，管道關閉後結束
+func (cl *ChunkPipe[T]) janitor(ttl time.Duration) {
+	ticker := time.NewTicker(max(ttl/4, stampResolution))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cl.mu.Lock()
+		if cl.closed {
+			cl.mu.Unlock()
+			return
+		}
+		n := cl.expired(time.Now().Add(-ttl))
+		cl.evict(cl.offset, n)
+		cl.discardFront(n)
+		cl.unlock(0, n)
+	}
+}
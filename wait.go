@@ -0,0 +1,115 @@
+package chunkpipe
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// PopFrontCtx 從頭部彈出一個元素，管道為空時阻塞等待資料，
+// ctx 結束時返回 ctx.Err()；管道關閉且已取完時返回 io.EOF
+func (cl *ChunkPipe[T]) PopFrontCtx(ctx context.Context) (T, error) {
+	return cl.popWait(ctx, cl.popFront)
+}
+
+// PopEndCtx 從尾端彈出一個元素，管道為空時阻塞等待資料，
+// ctx 結束時返回 ctx.Err()；管道關閉且已取完時返回 io.EOF
+func (cl *ChunkPipe[T]) PopEndCtx(ctx context.Context) (T, error) {
+	return cl.popWait(ctx, cl.popEnd)
+}
+
+// PopFrontTimeout 從頭部彈出一個元素，管道為空時最多等待 d，
+// 逾時或管道關閉且已取完時返回 false；適合需要定期做其他工作的消費迴圈。
+// d 小於等於 0 時不等待，等同 PopFront
+func (cl *ChunkPipe[T]) PopFrontTimeout(d time.Duration) (T, bool) {
+	if d <= 0 {
+		return cl.PopFront()
+	}
+	return cl.popTimeout(d, cl.popFront)
+}
+
+// PopEndTimeout 從尾端彈出一個元素，管道為空時最多等待 d，行為與 PopFrontTimeout 相同
+func (cl *ChunkPipe[T]) PopEndTimeout(d time.Duration) (T, bool) {
+	if d <= 0 {
+		return cl.PopEnd()
+	}
+	return cl.popTimeout(d, cl.popEnd)
+}
+
+// popTimeout 以 d 為期限呼叫 popWait
+func (cl *ChunkPipe[T]) popTimeout(d time.Duration, pop func() (T, bool)) (T, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	v, err := cl.popWait(ctx, pop)
+	return v, err == nil
+}
+
+// popWait 以寫鎖呼叫 pop，管道為空時等待 ready 被關閉後重試。
+// 多個等待者會同時被喚醒，未取得資料的一方重新等待
+func (cl *ChunkPipe[T]) popWait(ctx context.Context, pop func() (T, bool)) (T, error) {
+	for {
+		cl.mu.Lock()
+		if v, ok := pop(); ok {
+			cl.unlock(0, 1)
+			return v, nil
+		}
+		if cl.closed {
+			cl.mu.Unlock()
+			var zero T
+			return zero, io.EOF
+		}
+		if cl.ready == nil {
+			cl.ready = make(chan struct{})
+		}
+		ready := cl.ready
+		cl.mu.Unlock()
+
+		start := time.Now()
+		select {
+		case <-ready:
+			cl.blocked(start)
+		case <-ctx.Done():
+			cl.blocked(start)
+			var zero T
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// closedChan 是一個已關閉的通道，代表條件已經成立
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Readable 返回一個在管道有資料可取時可接收的通道，方便與 ctx 或計時器一起 select。
+// 呼叫當下已有資料或管道已關閉時返回已關閉的通道，否則在下一次推入時關閉。
+// 與 Notify 不同，每次等待前都應重新呼叫，且接收後資料仍可能被其他消費者先取走
+func (cl *ChunkPipe[T]) Readable() <-chan struct{} {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if len(cl.list) > 0 || cl.closed {
+		return closedChan
+	}
+	if cl.ready == nil {
+		cl.ready = make(chan struct{})
+	}
+	return cl.ready
+}
+
+// Writable 返回一個在管道有空間推入時可接收的通道。
+// 沒有上限、尚有空間或已關閉的管道返回已關閉的通道，否則在有元素被移除時關閉
+func (cl *ChunkPipe[T]) Writable() <-chan struct{} {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.maxLen == 0 || cl.len() < cl.maxLen || cl.closed {
+		return closedChan
+	}
+	if cl.space == nil {
+		cl.space = make(chan struct{})
+	}
+	return cl.space
+}